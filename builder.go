@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// profileBuilder builds a pprof profile, deduplicating the strings,
+// functions, locations and mappings added to it.
+type profileBuilder struct {
+	profile *pprof.Profile
+
+	strings   map[string]int64
+	functions map[string]uint64
+	locations map[string]uint64
+	mappings  map[string]uint64
+}
+
+func newProfileBuilder() *profileBuilder {
+	return &profileBuilder{
+		profile: &pprof.Profile{
+			// the first string of the table must be the empty string
+			StringTable: []string{""},
+		},
+		strings:   map[string]int64{"": 0},
+		functions: make(map[string]uint64),
+		locations: make(map[string]uint64),
+		mappings:  make(map[string]uint64),
+	}
+}
+
+// str returns the index of the given string in the strings table.
+func (b *profileBuilder) str(s string) int64 {
+	if idx, ok := b.strings[s]; ok {
+		return idx
+	}
+	idx := int64(len(b.profile.StringTable))
+	b.profile.StringTable = append(b.profile.StringTable, s)
+	b.strings[s] = idx
+	return idx
+}
+
+func (b *profileBuilder) valueType(typ, unit string) *pprof.ValueType {
+	return &pprof.ValueType{Type: b.str(typ), Unit: b.str(unit)}
+}
+
+// function returns the ID of the function, creating it if necessary.
+func (b *profileBuilder) function(name, systemName, filename string, startLine int64) uint64 {
+	key := fmt.Sprintf("%s\x00%s\x00%s\x00%d", name, systemName, filename, startLine)
+	if id, ok := b.functions[key]; ok {
+		return id
+	}
+
+	id := uint64(len(b.profile.Function) + 1)
+	b.profile.Function = append(b.profile.Function, &pprof.Function{
+		Id:         id,
+		Name:       b.str(name),
+		SystemName: b.str(systemName),
+		Filename:   b.str(filename),
+		StartLine:  startLine,
+	})
+	b.functions[key] = id
+	return id
+}
+
+// mapping returns the ID of the mapping, creating it if necessary.
+// The given mapping ID is ignored.
+func (b *profileBuilder) mapping(m *pprof.Mapping, filename, buildID string) uint64 {
+	key := fmt.Sprintf("%s\x00%s\x00%d\x00%d\x00%d", filename, buildID, m.GetMemoryStart(), m.GetMemoryLimit(), m.GetFileOffset())
+	if id, ok := b.mappings[key]; ok {
+		return id
+	}
+
+	id := uint64(len(b.profile.Mapping) + 1)
+	b.profile.Mapping = append(b.profile.Mapping, &pprof.Mapping{
+		Id:              id,
+		MemoryStart:     m.GetMemoryStart(),
+		MemoryLimit:     m.GetMemoryLimit(),
+		FileOffset:      m.GetFileOffset(),
+		Filename:        b.str(filename),
+		BuildId:         b.str(buildID),
+		HasFunctions:    m.GetHasFunctions(),
+		HasFilenames:    m.GetHasFilenames(),
+		HasLineNumbers:  m.GetHasLineNumbers(),
+		HasInlineFrames: m.GetHasInlineFrames(),
+	})
+	b.mappings[key] = id
+	return id
+}
+
+// location returns the ID of the location, creating it if necessary.
+// The lines must reference functions of this builder, the innermost first.
+func (b *profileBuilder) location(mappingID, address uint64, lines []*pprof.Line) uint64 {
+	var key strings.Builder
+	fmt.Fprintf(&key, "%d\x00%d", mappingID, address)
+	for _, line := range lines {
+		fmt.Fprintf(&key, "\x00%d:%d", line.GetFunctionId(), line.GetLine())
+	}
+
+	if id, ok := b.locations[key.String()]; ok {
+		return id
+	}
+
+	id := uint64(len(b.profile.Location) + 1)
+	b.profile.Location = append(b.profile.Location, &pprof.Location{
+		Id:        id,
+		MappingId: mappingID,
+		Address:   address,
+		Line:      lines,
+	})
+	b.locations[key.String()] = id
+	return id
+}
+
+// frame returns the ID of a location made of a single function,
+// used to import the frames of the text formats.
+func (b *profileBuilder) frame(name, filename string, line int64) uint64 {
+	functionID := b.function(name, name, filename, 0)
+	return b.location(0, 0, []*pprof.Line{{FunctionId: functionID, Line: line}})
+}
@@ -0,0 +1,117 @@
+package main
+
+import "sort"
+
+// butterfly lists the callers and the callees of a function, with
+// the cost flowing through each of them.
+type butterfly struct {
+	function Function
+
+	flat int64
+	cum  int64
+
+	callers []butterflyEdge
+	callees []butterflyEdge
+}
+
+// butterflyEdge is a caller or a callee of the butterfly function.
+// The function of a root caller or of the self cost has no name.
+type butterflyEdge struct {
+	function Function
+	value    int64
+	percent  float64
+}
+
+// Butterfly computes the callers and callees of the given function. The
+// functions are identified by name or by line of code depending on
+// opts.AggregateByFunction.
+func (p *Profile) Butterfly(function Function, opts TreeOptions) butterfly {
+	samples := p.Samples
+	total := float64(p.TotalSampling)
+	if p.Base != nil {
+		samples = p.diffSamples()
+		total = p.diffTotal()
+	}
+
+	lineNumber := !opts.AggregateByFunction
+	id := function.String(lineNumber)
+
+	function.Self = 0
+	rv := butterfly{function: function}
+
+	callers := make(map[string]*butterflyEdge)
+	callees := make(map[string]*butterflyEdge)
+	add := func(edges map[string]*butterflyEdge, f Function, value int64, seen map[string]bool) {
+		key := f.String(lineNumber)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		e, ok := edges[key]
+		if !ok {
+			f.Self = 0
+			e = &butterflyEdge{function: f}
+			edges[key] = e
+		}
+		e.value += value
+	}
+
+	for _, s := range samples {
+		if !opts.matchesTags(s.Labels) {
+			continue
+		}
+
+		// with recursion, the function appears several times in the stack,
+		// its callers and callees must be counted once per sample.
+		found := false
+		seenCallers := make(map[string]bool)
+		seenCallees := make(map[string]bool)
+
+		for i, f := range s.Functions {
+			if f.String(lineNumber) != id {
+				continue
+			}
+			found = true
+
+			if i > 0 {
+				add(callers, s.Functions[i-1], s.Value, seenCallers)
+			} else {
+				add(callers, Function{}, s.Value, seenCallers)
+			}
+
+			if i < len(s.Functions)-1 {
+				add(callees, s.Functions[i+1], s.Value, seenCallees)
+			} else {
+				add(callees, Function{}, s.Value, seenCallees)
+				rv.flat += s.Value
+			}
+		}
+
+		if found {
+			rv.cum += s.Value
+		}
+	}
+
+	rv.callers = sortedEdges(callers, total)
+	rv.callees = sortedEdges(callees, total)
+
+	return rv
+}
+
+func sortedEdges(edges map[string]*butterflyEdge, total float64) []butterflyEdge {
+	rv := make([]butterflyEdge, 0, len(edges))
+	for _, e := range edges {
+		e.percent = float64(e.value) / total * 100.0
+		rv = append(rv, *e)
+	}
+
+	sort.Slice(rv, func(i, j int) bool {
+		if abs(rv[i].value) != abs(rv[j].value) {
+			return abs(rv[i].value) > abs(rv[j].value)
+		}
+		return rv[i].function.Name < rv[j].function.Name
+	})
+
+	return rv
+}
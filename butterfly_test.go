@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestButterfly(t *testing.T) {
+	tenantA := []Label{{Key: "tenant", Str: "a"}}
+	profile := &Profile{
+		Samples: Samples{
+			stackSample(5, nil, "main", "a", "b", "a", "c"),
+			stackSample(3, tenantA, "main", "a", "a"),
+			stackSample(2, tenantA, "main", "x"),
+		},
+		TotalSampling: 10,
+	}
+
+	tenant, err := parseTagFilter("tenant=a")
+	if err != nil {
+		t.Fatalf("parseTagFilter() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		function string
+		opts     TreeOptions
+		flat     int64
+		cum      int64
+		callers  map[string]int64
+		callees  map[string]int64
+	}{
+		{
+			name:     "recursion",
+			function: "a",
+			flat:     3,
+			cum:      8,
+			// counted once per sample
+			callers: map[string]int64{"main": 8, "b": 5, "a": 3},
+			callees: map[string]int64{"b": 5, "c": 5, "a": 3, "": 3},
+		},
+		{
+			name:     "root",
+			function: "main",
+			cum:      10,
+			callers:  map[string]int64{"": 10},
+			callees:  map[string]int64{"a": 8, "x": 2},
+		},
+		{
+			name:     "tag focus",
+			function: "a",
+			opts:     TreeOptions{TagFocus: []tagFilter{tenant}},
+			flat:     3,
+			cum:      3,
+			callers:  map[string]int64{"main": 3, "a": 3},
+			callees:  map[string]int64{"a": 3, "": 3},
+		},
+		{
+			name:     "tag ignore",
+			function: "main",
+			opts:     TreeOptions{TagIgnore: []tagFilter{tenant}},
+			cum:      5,
+			callers:  map[string]int64{"": 5},
+			callees:  map[string]int64{"a": 5},
+		},
+	}
+
+	edges := func(edges []butterflyEdge) map[string]int64 {
+		rv := make(map[string]int64)
+		for _, e := range edges {
+			rv[e.function.Name] = e.value
+		}
+		return rv
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.AggregateByFunction = true
+			b := profile.Butterfly(Function{Name: test.function}, test.opts)
+			if b.flat != test.flat || b.cum != test.cum {
+				t.Errorf("Butterfly() flat, cum = %d, %d, want %d, %d", b.flat, b.cum, test.flat, test.cum)
+			}
+			if got := edges(b.callers); !reflect.DeepEqual(got, test.callers) {
+				t.Errorf("Butterfly() callers = %v, want %v", got, test.callers)
+			}
+			if got := edges(b.callees); !reflect.DeepEqual(got, test.callees) {
+				t.Errorf("Butterfly() callees = %v, want %v", got, test.callees)
+			}
+		})
+	}
+}
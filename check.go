@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// checkRule is a rule the profile must respect, parsed from:
+//
+//	total<+10%       the total must not grow more than 10% compared to the base
+//	func:REGEX<5%    the functions matching REGEX must stay under 5% of the total
+//	func:REGEX<+10%  the functions matching REGEX must not grow more than 10%
+type checkRule struct {
+	raw string
+
+	// nil for a rule on the total
+	function *regexp.Regexp
+	// growth compared to the base profile, or share of the total
+	growth bool
+	limit  float64
+}
+
+// checkResult is the verdict of a rule.
+type checkResult struct {
+	Rule   string  `json:"rule"`
+	Passed bool    `json:"passed"`
+	Value  float64 `json:"value"`
+	Limit  float64 `json:"limit"`
+	// New is true when the functions of a growth rule didn't exist in
+	// the base profile, an infinite growth can't be represented in JSON.
+	New     bool   `json:"new"`
+	Message string `json:"message"`
+}
+
+// checkVerdict is the machine-readable verdict of the check command.
+type checkVerdict struct {
+	Passed bool          `json:"passed"`
+	Sample string        `json:"sample"`
+	Rules  []checkResult `json:"rules"`
+}
+
+func parseCheckRule(raw string) (checkRule, error) {
+	rule := checkRule{raw: raw}
+
+	// the limit is after the last '<', the regex may contain some
+	idx := strings.LastIndex(raw, "<")
+	if idx < 0 {
+		return rule, fmt.Errorf("parseCheckRule: %q: missing '<'", raw)
+	}
+	subject, limit := strings.TrimSpace(raw[:idx]), strings.TrimSpace(raw[idx+1:])
+
+	switch {
+	case subject == "total":
+	case strings.HasPrefix(subject, "func:"):
+		re, err := regexp.Compile(strings.TrimPrefix(subject, "func:"))
+		if err != nil {
+			return rule, fmt.Errorf("parseCheckRule: %q: %v", raw, err)
+		}
+		rule.function = re
+	default:
+		return rule, fmt.Errorf("parseCheckRule: %q: unknown subject %q, expected total or func:REGEX", raw, subject)
+	}
+
+	if !strings.HasSuffix(limit, "%") {
+		return rule, fmt.Errorf("parseCheckRule: %q: the limit must be a percentage", raw)
+	}
+	rule.growth = strings.HasPrefix(limit, "+")
+	if rule.function == nil && !rule.growth {
+		return rule, fmt.Errorf("parseCheckRule: %q: the total can only be checked for growth, e.g. total<+10%%", raw)
+	}
+
+	var err error
+	if rule.limit, err = strconv.ParseFloat(strings.TrimSuffix(strings.TrimPrefix(limit, "+"), "%"), 64); err != nil {
+		return rule, fmt.Errorf("parseCheckRule: %q: %v", raw, err)
+	}
+
+	return rule, nil
+}
+
+// check evaluates the rules on the profile and prints the verdict as JSON
+// on the standard output. passed is false if a rule has been violated.
+func check(pprofProfile *pprof.Profile, base *pprof.Profile) (passed bool, err error) {
+	if len(config.Rules) == 0 {
+		return false, fmt.Errorf("check: no rule to check, use -rule")
+	}
+
+	var rules []checkRule
+	for _, raw := range config.Rules {
+		rule, err := parseCheckRule(raw)
+		if err != nil {
+			return false, fmt.Errorf("check: %v", err)
+		}
+		if rule.growth && base == nil {
+			return false, fmt.Errorf("check: %q: checking a growth needs a base profile, use -base", raw)
+		}
+		rules = append(rules, rule)
+	}
+
+	profile, err := NewProfileWithBase(pprofProfile, base, sampleMode(config.SampleType), config.PruneRules)
+	if err != nil {
+		return false, fmt.Errorf("check: %v", err)
+	}
+
+	verdict := checkVerdict{
+		Passed: true,
+		Sample: profile.SampleType.Type,
+	}
+	opts := config.TreeOptions()
+	for _, rule := range rules {
+		result := rule.evaluate(profile, opts)
+		verdict.Passed = verdict.Passed && result.Passed
+		verdict.Rules = append(verdict.Rules, result)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(verdict); err != nil {
+		return false, fmt.Errorf("check: %v", err)
+	}
+
+	return verdict.Passed, nil
+}
+
+// evaluate checks the rule on the stacks of the profile as they are in the
+// tree built with opts: the values are the ones of the samples matching
+// the tag filters, with the recursions collapsed if enabled.
+func (r checkRule) evaluate(profile *Profile, opts TreeOptions) checkResult {
+	unit := profile.SampleType.Unit
+	result := checkResult{Rule: r.raw, Limit: r.limit}
+
+	subject := "total"
+	if r.function != nil {
+		subject = fmt.Sprintf("functions matching %q", r.function.String())
+	}
+	value := profile.matchingValue(r.function, opts)
+
+	if !r.growth {
+		// the share of the total of the same samples
+		if total := profile.matchingValue(nil, opts); total > 0 {
+			result.Value = float64(value) / float64(total) * 100.0
+		}
+		result.Passed = result.Value <= r.limit
+		result.Message = fmt.Sprintf("%s: %s, %.2f%% of the total (limit %.2f%%)", subject, formatValue(value, unit), result.Value, r.limit)
+		return result
+	}
+
+	baseValue := profile.Base.matchingValue(r.function, opts)
+
+	switch {
+	case baseValue != 0:
+		result.Value = float64(value-baseValue) / float64(baseValue) * 100.0
+		result.Passed = result.Value <= r.limit
+	case value == 0:
+		result.Passed = true
+	default:
+		result.New = true
+		result.Passed = false
+		result.Message = fmt.Sprintf("%s: %s -> %s, new (limit +%.2f%%)", subject, formatValue(baseValue, unit), formatValue(value, unit), r.limit)
+		return result
+	}
+	result.Message = fmt.Sprintf("%s: %s -> %s, %+.2f%% (limit +%.2f%%)", subject, formatValue(baseValue, unit), formatValue(value, unit), result.Value, r.limit)
+
+	return result
+}
+
+// matchingValue returns the value of the samples in which a function
+// matching the regex appears, or of all the samples if re is nil. The
+// stacks are the ones of the tree built with opts, without the frames
+// grouping them by label which aren't functions.
+func (p *Profile) matchingValue(re *regexp.Regexp, opts TreeOptions) int64 {
+	opts.GroupBy = ""
+
+	var rv int64
+	for _, s := range p.Samples {
+		functions, ok := opts.stack(s)
+		if !ok {
+			continue
+		}
+		if re == nil {
+			rv += s.Value
+			continue
+		}
+		for _, f := range functions {
+			if re.MatchString(f.Name) {
+				rv += s.Value
+				break
+			}
+		}
+	}
+	return rv
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseCheckRule(t *testing.T) {
+	tests := []struct {
+		raw      string
+		function string
+		growth   bool
+		limit    float64
+		err      string
+	}{
+		{raw: "total<+10%", growth: true, limit: 10},
+		{raw: "total < +2.5%", growth: true, limit: 2.5},
+		{raw: "func:runtime\\.mallocgc<5%", function: "runtime\\.mallocgc", limit: 5},
+		{raw: "func:^main\\.<+20%", function: "^main\\.", growth: true, limit: 20},
+		{raw: "func:a<b<1%", function: "a<b", limit: 1},
+		{raw: "total", err: "missing '<'"},
+		{raw: "total<10%", err: "only be checked for growth"},
+		{raw: "total<+10", err: "must be a percentage"},
+		{raw: "memory<+10%", err: "unknown subject"},
+		{raw: "func:(<5%", err: "missing closing )"},
+		{raw: "func:a<+x%", err: "invalid syntax"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.raw, func(t *testing.T) {
+			rule, err := parseCheckRule(test.raw)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("parseCheckRule() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCheckRule() error = %v", err)
+			}
+
+			function := ""
+			if rule.function != nil {
+				function = rule.function.String()
+			}
+			if function != test.function || rule.growth != test.growth || rule.limit != test.limit {
+				t.Errorf("parseCheckRule() = {%q %v %v}, want {%q %v %v}",
+					function, rule.growth, rule.limit, test.function, test.growth, test.limit)
+			}
+		})
+	}
+}
+
+func TestCheckRuleEvaluate(t *testing.T) {
+	label := []Label{{Key: "k", Str: "v"}}
+	base := &Profile{
+		Samples: Samples{
+			stackSample(10, nil, "main", "work"),
+			stackSample(10, label, "main", "other"),
+		},
+		TotalSampling: 20,
+	}
+	profile := &Profile{
+		Samples: Samples{
+			stackSample(12, nil, "main", "work"),
+			stackSample(10, label, "main", "other"),
+			stackSample(5, label, "main", "added"),
+		},
+		TotalSampling: 27,
+		Base:          base,
+	}
+	tagFocus := TreeOptions{TagFocus: []tagFilter{{key: "k", value: "v"}}}
+
+	tests := []struct {
+		name    string
+		rule    string
+		profile *Profile
+		opts    TreeOptions
+		passed  bool
+		value   float64
+		new     bool
+		message string
+	}{
+		{name: "total growth", rule: "total<+40%", profile: profile, passed: true, value: 35},
+		{name: "total growth violated", rule: "total<+10%", profile: profile, value: 35},
+		{name: "share", rule: "func:work<50%", profile: profile, passed: true, value: 12.0 / 27 * 100},
+		{name: "function growth", rule: "func:work<+10%", profile: profile, value: 20},
+		{name: "new function", rule: "func:added<+10%", profile: profile, new: true, message: "new"},
+		{name: "absent function", rule: "func:none<+10%", profile: profile, passed: true},
+		{name: "tag focus", rule: "total<+40%", profile: profile, opts: tagFocus, value: 50},
+		{name: "tag focus share", rule: "func:work<1%", profile: profile, opts: tagFocus, passed: true},
+		{name: "share of the filtered total", rule: "func:added<30%", profile: profile, opts: tagFocus, value: 5.0 / 15 * 100},
+		{name: "group by frame", rule: "func:^k=<1%", profile: profile, opts: TreeOptions{GroupBy: "k"}, passed: true},
+		{name: "empty profile", rule: "func:work<5%", profile: &Profile{}, passed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := parseCheckRule(test.rule)
+			if err != nil {
+				t.Fatalf("parseCheckRule() error = %v", err)
+			}
+
+			result := rule.evaluate(test.profile, test.opts)
+			if result.Passed != test.passed || result.New != test.new {
+				t.Errorf("evaluate() = %+v, want passed %v, new %v", result, test.passed, test.new)
+			}
+			if diff := result.Value - test.value; diff > 0.001 || diff < -0.001 {
+				t.Errorf("evaluate() value = %v, want %v", result.Value, test.value)
+			}
+			if !strings.Contains(result.Message, test.message) {
+				t.Errorf("evaluate() message = %q, want %q", result.Message, test.message)
+			}
+			if _, err := json.Marshal(result); err != nil {
+				t.Errorf("json.Marshal() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// cpuProfile is a .cpuprofile file, as saved by the Chrome DevTools or
+// by Node.js (--cpu-prof or the inspector), and the Profile type of the
+// Chrome DevTools protocol.
+type cpuProfile struct {
+	Nodes      []cpuProfileNode `json:"nodes"`
+	StartTime  int64            `json:"startTime"`
+	EndTime    int64            `json:"endTime"`
+	Samples    []int64          `json:"samples"`
+	TimeDeltas []int64          `json:"timeDeltas"`
+}
+
+type cpuProfileNode struct {
+	ID        int64 `json:"id"`
+	CallFrame struct {
+		FunctionName string `json:"functionName"`
+		URL          string `json:"url"`
+		// LineNumber and ColumnNumber are 0-based.
+		LineNumber   int64 `json:"lineNumber"`
+		ColumnNumber int64 `json:"columnNumber"`
+	} `json:"callFrame"`
+	HitCount int64   `json:"hitCount"`
+	Children []int64 `json:"children"`
+	// Parent is used instead of Children in the ProfileChunk trace events.
+	Parent int64 `json:"parent"`
+}
+
+// looksLikeCpuProfile returns true if the JSON data is a .cpuprofile.
+func looksLikeCpuProfile(data []byte) bool {
+	var probe struct {
+		Nodes []struct {
+			ID        *int64           `json:"id"`
+			CallFrame *json.RawMessage `json:"callFrame"`
+		} `json:"nodes"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil || len(probe.Nodes) == 0 {
+		return false
+	}
+	return probe.Nodes[0].ID != nil && probe.Nodes[0].CallFrame != nil
+}
+
+// readCpuProfile converts a .cpuprofile to a pprof profile with a
+// "samples" sample type and a "cpu" sample type in microseconds, the
+// time elapsed until the next sample.
+func readCpuProfile(data []byte) (*pprof.Profile, error) {
+	var cp cpuProfile
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("readCpuProfile: json.Unmarshal: %v", err)
+	}
+
+	nodes := make(map[int64]*cpuProfileNode, len(cp.Nodes))
+	parents := make(map[int64]int64, len(cp.Nodes))
+	for i := range cp.Nodes {
+		n := &cp.Nodes[i]
+		nodes[n.ID] = n
+		if n.Parent != 0 {
+			parents[n.ID] = n.Parent
+		}
+	}
+	for _, n := range cp.Nodes {
+		for _, child := range n.Children {
+			parents[child] = n.ID
+		}
+	}
+
+	b := newProfileBuilder()
+	b.profile.SampleType = []*pprof.ValueType{
+		b.valueType("samples", "count"),
+		b.valueType("cpu", "microseconds"),
+	}
+	b.profile.PeriodType = b.valueType("cpu", "microseconds")
+	b.profile.DurationNanos = (cp.EndTime - cp.StartTime) * 1000
+
+	// the stack of a node, the leaf first
+	stacks := make(map[int64][]uint64)
+	stack := func(id int64) ([]uint64, error) {
+		if s, ok := stacks[id]; ok {
+			return s, nil
+		}
+		var s []uint64
+		for current := id; current != 0; current = parents[current] {
+			n, ok := nodes[current]
+			if !ok {
+				return nil, fmt.Errorf("readCpuProfile: unknown node %d", current)
+			}
+			if len(s) > len(nodes) {
+				return nil, fmt.Errorf("readCpuProfile: cycle in the parents of the node %d", id)
+			}
+			// the root node isn't a frame
+			if n.CallFrame.FunctionName == "(root)" {
+				continue
+			}
+			s = append(s, b.cpuProfileLocation(n))
+		}
+		stacks[id] = s
+		return s, nil
+	}
+
+	// without samples, e.g. in the old .cpuprofile files,
+	// only the hit counts of the nodes are available.
+	if len(cp.Samples) == 0 {
+		b.profile.DefaultSampleType = b.str("samples")
+		for _, n := range cp.Nodes {
+			if n.HitCount == 0 {
+				continue
+			}
+			s, err := stack(n.ID)
+			if err != nil {
+				return nil, err
+			}
+			b.profile.Sample = append(b.profile.Sample, &pprof.Sample{
+				LocationId: s,
+				Value:      []int64{n.HitCount, 0},
+			})
+		}
+		return b.profile, nil
+	}
+
+	if len(cp.TimeDeltas) != len(cp.Samples) {
+		return nil, fmt.Errorf("readCpuProfile: %d samples but %d time deltas", len(cp.Samples), len(cp.TimeDeltas))
+	}
+
+	// aggregate the samples of the same node
+	values := make(map[int64][]int64)
+	var ids []int64
+	for i, id := range cp.Samples {
+		// a sample lasts until the next one, the last one until the end
+		var duration int64
+		if i+1 < len(cp.TimeDeltas) {
+			duration = cp.TimeDeltas[i+1]
+		} else {
+			timestamp := cp.StartTime
+			for _, delta := range cp.TimeDeltas {
+				timestamp += delta
+			}
+			duration = cp.EndTime - timestamp
+		}
+		if duration < 0 {
+			duration = 0
+		}
+
+		v, ok := values[id]
+		if !ok {
+			v = make([]int64, 2)
+			values[id] = v
+			ids = append(ids, id)
+		}
+		v[0]++
+		v[1] += duration
+	}
+
+	for _, id := range ids {
+		s, err := stack(id)
+		if err != nil {
+			return nil, err
+		}
+		b.profile.Sample = append(b.profile.Sample, &pprof.Sample{
+			LocationId: s,
+			Value:      values[id],
+		})
+	}
+
+	return b.profile, nil
+}
+
+// cpuProfileLocation returns the ID of the location of a node.
+func (b *profileBuilder) cpuProfileLocation(n *cpuProfileNode) uint64 {
+	name := n.CallFrame.FunctionName
+	if name == "" {
+		name = "(anonymous)"
+	}
+	line := n.CallFrame.LineNumber + 1
+	if line < 0 {
+		line = 0
+	}
+	return b.frame(name, n.CallFrame.URL, line)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const cpuProfileData = `{
+	"nodes": [
+		{"id": 1, "callFrame": {"functionName": "(root)", "url": "", "lineNumber": -1}, "children": [2, 4]},
+		{"id": 2, "callFrame": {"functionName": "main", "url": "file:///app.js", "lineNumber": 0}, "children": [3]},
+		{"id": 3, "callFrame": {"functionName": "foo", "url": "file:///app.js", "lineNumber": 9}},
+		{"id": 4, "callFrame": {"functionName": "", "url": "file:///lib.js", "lineNumber": 4}}
+	],
+	"startTime": 1000,
+	"endTime": 1200,
+	"samples": [3, 3, 2, 4],
+	"timeDeltas": [0, 100, 50, 30]
+}`
+
+func TestLooksLikeCpuProfile(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"cpuprofile", cpuProfileData, true},
+		{"no nodes", `{"nodes": []}`, false},
+		{"nodes without call frame", `{"nodes": [{"id": 1}]}`, false},
+		{"speedscope", `{"$schema": "https://www.speedscope.app/file-format-schema.json"}`, false},
+		{"not json", "main;foo 1\n", false},
+	}
+
+	for _, test := range tests {
+		if got := looksLikeCpuProfile([]byte(test.data)); got != test.want {
+			t.Errorf("%s: looksLikeCpuProfile() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestReadCpuProfile(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		mode sampleMode
+		want map[string]int64
+	}{
+		{
+			name: "cpu",
+			data: cpuProfileData,
+			mode: ModeCpu,
+			want: map[string]int64{"main;foo": 150, "main": 30, "(anonymous)": 20},
+		},
+		{
+			name: "samples",
+			data: cpuProfileData,
+			mode: "samples",
+			want: map[string]int64{"main;foo": 2, "main": 1, "(anonymous)": 1},
+		},
+		{
+			name: "hit counts",
+			data: `{"nodes": [
+				{"id": 1, "callFrame": {"functionName": "(root)"}, "hitCount": 3, "children": [2]},
+				{"id": 2, "callFrame": {"functionName": "main"}, "hitCount": 5}
+			]}`,
+			mode: ModeDefault,
+			want: map[string]int64{"main": 5},
+		},
+		{
+			name: "parents",
+			data: `{"nodes": [
+				{"id": 1, "callFrame": {"functionName": "(root)"}},
+				{"id": 2, "callFrame": {"functionName": "main"}, "parent": 1},
+				{"id": 3, "callFrame": {"functionName": "foo"}, "parent": 2}
+			], "startTime": 0, "endTime": 10, "samples": [3], "timeDeltas": [0]}`,
+			mode: ModeCpu,
+			want: map[string]int64{"main;foo": 10},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := readCpuProfile([]byte(test.data))
+			if err != nil {
+				t.Fatalf("readCpuProfile() error = %v", err)
+			}
+			equalStacks(t, foldedStacks(t, p, test.mode), test.want)
+		})
+	}
+}
+
+func TestReadCpuProfileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"invalid json", `{"nodes": [`, "json.Unmarshal"},
+		{"time deltas", `{"nodes": [{"id": 1, "callFrame": {}}], "samples": [1, 1], "timeDeltas": [0]}`, "2 samples but 1 time deltas"},
+		{"unknown node", `{"nodes": [{"id": 1, "callFrame": {}}], "samples": [2], "timeDeltas": [0]}`, "unknown node 2"},
+		{
+			"cycle",
+			`{"nodes": [{"id": 1, "callFrame": {}, "parent": 2}, {"id": 2, "callFrame": {}, "parent": 1}], "samples": [1], "timeDeltas": [0]}`,
+			"cycle in the parents of the node 1",
+		},
+	}
+
+	for _, test := range tests {
+		if _, err := readCpuProfile([]byte(test.data)); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: readCpuProfile() error = %v, want %q", test.name, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+// diffSamples returns the samples of the profile and the samples of its
+// base profile with negated values, building a tree with them results in
+// a tree of deltas between both profiles.
+// The percentages are relative to the diffTotal of the profile.
+func (p *Profile) diffSamples() Samples {
+	rv := make(Samples, 0, len(p.Samples)+len(p.Base.Samples))
+	baseTotal := p.diffTotal()
+
+	for _, s := range p.Samples {
+		s.PercentTotal = float64(s.Value) / baseTotal * 100.0
+		rv = append(rv, s)
+	}
+
+	for _, s := range p.Base.Samples {
+		// don't modify the functions of the base profile,
+		// the leaf self value is negated in a copy.
+		functions := make([]Function, len(s.Functions))
+		copy(functions, s.Functions)
+		functions[len(functions)-1].Self = -functions[len(functions)-1].Self
+
+		// the bytes and objects of the base samples are left out, the
+		// nodes keep the ones of the profile to compute their average
+		// object size, a delta of bytes per delta of objects is meaningless.
+		rv = append(rv, Sample{
+			Functions:    functions,
+			Value:        -s.Value,
+			BaseValue:    s.Value,
+			PercentTotal: -float64(s.Value) / baseTotal * 100.0,
+			Labels:       s.Labels,
+		})
+	}
+
+	return rv
+}
+
+// diffTotal returns the total the percentages of the differential view are
+// relative to: the base profile total, or the profile total when the base
+// profile is empty (e.g. a heap profile taken before any allocation).
+func (p *Profile) diffTotal() float64 {
+	if p.Base.TotalSampling == 0 {
+		return float64(p.TotalSampling)
+	}
+	return float64(p.Base.TotalSampling)
+}
+
+// percentChange returns the change of the node value compared to its
+// value in the base profile, ok is false if the node didn't exist
+// in the base profile.
+func (n *treeNode) percentChange() (change float64, ok bool) {
+	if n.base == 0 {
+		return 0, false
+	}
+	return float64(n.value) / float64(n.base) * 100.0, true
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+)
+
+// heapSample returns an alloc_space sample of the stack main -> f
+// allocating the given objects of the given size.
+func heapSample(f string, objects, size int64) Sample {
+	return Sample{
+		Functions: []Function{{Name: "main"}, {Name: f, Self: objects * size}},
+		Value:     objects * size,
+		Bytes:     objects * size,
+		Objects:   objects,
+	}
+}
+
+func TestDiffAverageObjectSize(t *testing.T) {
+	base := &Profile{
+		Samples:        Samples{heapSample("f", 10, 64)},
+		TotalSampling:  640,
+		HasObjectSizes: true,
+	}
+	profile := &Profile{
+		Samples:        Samples{heapSample("f", 4, 256)},
+		TotalSampling:  1024,
+		HasObjectSizes: true,
+		Base:           base,
+	}
+
+	tree := profile.BuildTree("test", TreeOptions{AggregateByFunction: true})
+	f := tree.root.children[0].children[0]
+
+	if f.value != 1024-640 {
+		t.Errorf("value = %d, want %d", f.value, 1024-640)
+	}
+	// the average is the one of the new profile, not (1024-640)/(4-10)
+	if avg := f.averageObjectSize(); avg != 256 {
+		t.Errorf("averageObjectSize() = %d, want 256", avg)
+	}
+}
+
+func TestDiffEmptyBase(t *testing.T) {
+	profile := &Profile{
+		Samples:       Samples{stackSample(8, nil, "main", "f")},
+		TotalSampling: 8,
+		SampleType:    ValueType{Type: "inuse_space", Unit: "bytes"},
+		Base:          &Profile{},
+	}
+
+	for _, s := range profile.diffSamples() {
+		if s.PercentTotal != 100 {
+			t.Errorf("diffSamples() percent = %v, want 100", s.PercentTotal)
+		}
+	}
+
+	tree := profile.BuildTree("test", TreeOptions{AggregateByFunction: true})
+	value, _, _, _ := profile.nodeTexts(tree.root.children[0], true)
+	if want := "+8 B (new)"; value != want {
+		t.Errorf("nodeTexts() value = %q, want %q", value, want)
+	}
+
+	header := profile.headerText("test", TreeOptions{})
+	if want := "test - total in-use memory: 8 B - base: 0 B - delta: +8 B (new)"; header != want {
+		t.Errorf("headerText() = %q, want %q", header, want)
+	}
+}
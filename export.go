@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// export writes the profile to config.Output in the format config.Format,
+// honoring the same options as the GUI.
+func export(pprofProfile *pprof.Profile, base *pprof.Profile) error {
+	profile, err := NewProfileWithBase(pprofProfile, base, sampleMode(config.SampleType), config.PruneRules)
+	if err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+
+	opts := config.TreeOptions()
+
+	// render in memory to not create or truncate the
+	// output file if the export fails.
+	var buf bytes.Buffer
+	switch config.Format {
+	case "svg":
+		tree := profile.BuildTree(config.Source(), opts)
+		err = writeSVG(&buf, profile, tree, opts, config.Icicle)
+	case "folded":
+		err = writeFolded(&buf, profile, opts)
+	case "speedscope":
+		err = writeSpeedscope(&buf, profile, path.Base(config.Source()), opts)
+	default:
+		return fmt.Errorf("export: unknown format %q", config.Format)
+	}
+	if err != nil {
+		return fmt.Errorf("export: %v", err)
+	}
+
+	if config.Output == "" || config.Output == "-" {
+		if _, err := buf.WriteTo(os.Stdout); err != nil {
+			return fmt.Errorf("export: %v", err)
+		}
+		return nil
+	}
+
+	if err := os.WriteFile(config.Output, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("export: os.WriteFile: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	p, err := readFolded([]byte("main;a 3\nmain;b 1\n"))
+	if err != nil {
+		t.Fatalf("readFolded() error = %v", err)
+	}
+
+	defer func(c Config) { config = c }(config)
+
+	tests := []struct {
+		format string
+		want   string
+		err    string
+	}{
+		{format: "folded", want: "main;a 3\nmain;b 1\n"},
+		{format: "svg", want: "<?xml"},
+		{format: "speedscope", want: speedscopeSchema},
+		{format: "nope", want: "keep me", err: `unknown format "nope"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "output")
+			if err := os.WriteFile(output, []byte("keep me"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			config = Config{File: "test.folded", Format: test.format, Output: output, Aggregate: true}
+
+			err := export(p, nil)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("export() error = %v, want %q", err, test.err)
+				}
+			} else if err != nil {
+				t.Errorf("export() error = %v", err)
+			}
+
+			// the output file is left untouched on error
+			data, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), test.want) {
+				t.Errorf("output = %q, want %q", data, test.want)
+			}
+		})
+	}
+}
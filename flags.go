@@ -1,14 +1,247 @@
 package main
 
-import "flag"
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	File string
+
+	// baseline profile, displays a differential view if set
+	Base string
+
+	// sample type to read, the default one of the profile if empty
+	SampleType string
+
+	// profile fetched from a net/http/pprof endpoint
+	URL       string
+	Timeout   time.Duration
+	Headers   headersFlag
+	BasicAuth string
+
+	// tree options
+	Aggregate bool
+	Search    string
+	Inverted  bool
+	Collapse  bool
+	TagFocus  tagFiltersFlag
+	TagIgnore tagFiltersFlag
+	GroupBy   string
+
+	// frames pruned from the stacks
+	PruneRules []pruneRule
+
+	// export command
+	Format string
+	Output string
+	Icicle bool
+
+	// report command
+	Text         bool
+	Depth        int
+	NodeFraction float64
+	Top          int
+	TopSort      string
+
+	// check command
+	Rules rulesFlag
+}
+
+// Source returns the name of the location the profile is read from.
+func (c Config) Source() string {
+	if c.URL != "" {
+		return c.URL
+	}
+	if c.File == "-" {
+		return "stdin"
+	}
+	return c.File
+}
+
+// TreeOptions returns the options to build the tree with.
+func (c Config) TreeOptions() TreeOptions {
+	return TreeOptions{
+		AggregateByFunction: c.Aggregate,
+		SearchField:         c.Search,
+		Inverted:            c.Inverted,
+		TagFocus:            c.TagFocus,
+		TagIgnore:           c.TagIgnore,
+		GroupBy:             c.GroupBy,
+		CollapseRecursion:   c.Collapse,
+	}
 }
 
 var config Config
 
-func init() {
-	flag.StringVar(&config.File, "file", "", "Profile or heap snapshot file to read")
-	flag.Parse()
+// commands are the available commands and their description,
+// without command, the profile is opened in the GUI.
+var commands = map[string]string{
+	"export": "Export the profile in another format (e.g. a SVG flame graph) without opening the GUI",
+	"report": "Print the tree or the flat top table of the profile as text, without opening the GUI",
+	"check":  "Check the profile against rules (e.g. the total must not grow more than 10% compared to -base), for CI",
+}
+
+// parseFlags parses the command line: an optional command followed by
+// its flags. It returns the command and the flag set used to parse it.
+func parseFlags(args []string) (string, *flag.FlagSet) {
+	var command string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	fs := flag.CommandLine
+	if command != "" {
+		fs = flag.NewFlagSet("diago "+command, flag.ExitOnError)
+	}
+	fs.Usage = func() { usage(fs) }
+
+	if _, ok := commands[command]; command != "" && !ok {
+		fmt.Fprintf(fs.Output(), "unknown command: %s\n", command)
+		fs.Usage()
+		os.Exit(-1)
+	}
+
+	fs.StringVar(&config.File, "file", "", "Profile or heap snapshot file to read, - to read from the standard input. Several comma-separated files or glob patterns are merged in a single profile")
+	fs.StringVar(&config.Base, "base", "", "Baseline profile to compare the profile to, displays the differences between both. Accepts several files as -file does")
+	fs.StringVar(&config.SampleType, "sample", "", "Sample type to read (e.g. alloc_space, inuse_objects, contentions, delay), the default one of the profile if empty")
+	fs.StringVar(&config.URL, "url", "", "URL of a net/http/pprof endpoint to fetch the profile from, e.g. http://localhost:6060/debug/pprof/heap")
+	fs.DurationVar(&config.Timeout, "timeout", 60*time.Second, "Timeout of the HTTP request, the 'seconds' parameter of the URL is added to it")
+	fs.Var(&config.Headers, "header", "Header to send with the HTTP request, e.g. \"Authorization: Bearer token\" (can be repeated)")
+	fs.StringVar(&config.BasicAuth, "basic-auth", "", "Credentials to use for the HTTP request, as user:password")
+
+	fs.BoolVar(&config.Aggregate, "aggregate", true, "Aggregate by functions, set to false to have the information up to the lines of code")
+	fs.StringVar(&config.Search, "search", "", "Only display the functions and files matching this search")
+	fs.BoolVar(&config.Inverted, "inverted", false, "Build the tree from the leaves to the roots")
+	fs.BoolVar(&config.Collapse, "collapse-recursion", false, "Collapse the recursive calls, direct or indirect, in a single node")
+	fs.Var(&config.TagFocus, "tag-focus", "Only keep the samples with a matching label: key=value, key~regex, key=min:max for numeric labels or key (can be repeated)")
+	fs.Var(&config.TagIgnore, "tag-ignore", "Ignore the samples with a matching label, same syntax as -tag-focus (can be repeated)")
+	fs.Var(pruneRulesFlag{PruneDrop, &config.PruneRules}, "drop-frames", "Drop the frames matching this regexp and their callees, their cost is attributed to the caller (can be repeated)")
+	fs.Var(pruneRulesFlag{PruneIgnore, &config.PruneRules}, "ignore-frames", "Hide the frames matching this regexp, their cost is attributed to their callers (can be repeated)")
+	fs.StringVar(&config.GroupBy, "group-by", "", "Label key to group the tree by, with a root node per value of the label (e.g. route)")
+
+	if command == "export" {
+		fs.StringVar(&config.Format, "format", "svg", "Export format: svg, folded or speedscope")
+		fs.StringVar(&config.Output, "o", "-", "File to write the export to, - for the standard output")
+		fs.BoolVar(&config.Icicle, "icicle", false, "Draw an icicle graph (roots at the top) instead of a flame graph")
+	}
+
+	if command == "" || command == "report" {
+		if command == "" {
+			fs.BoolVar(&config.Text, "text", false, "Print the profile as text instead of opening the GUI, as the report command does")
+		}
+		fs.IntVar(&config.Depth, "depth", 0, "Report: maximum depth of the printed tree, 0 for no limit")
+		fs.Float64Var(&config.NodeFraction, "node-fraction", 0.005, "Report: hide the nodes which value is less than this fraction of the total")
+		fs.IntVar(&config.Top, "top", 0, "Report: print the N first entries of the flat top table instead of the tree")
+		fs.StringVar(&config.TopSort, "top-sort", string(TopSortFlat), "Report: column to sort the flat top table by: flat, cum or name")
+	}
+
+	if command == "check" {
+		fs.Var(&config.Rules, "rule", "Rule to check (can be repeated): total<+10% (growth compared to -base), func:REGEX<5% (share of the total) or func:REGEX<+10% (growth)")
+	}
+
+	fs.Parse(args)
+
+	if config.Text {
+		command = "report"
+	}
+
+	return command, fs
+}
+
+func usage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintf(out, "usage: diago [command] [flags]\n\n")
+	fmt.Fprintf(out, "Without command, the profile is opened in the GUI. Commands:\n")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s\n    \t%s\n", name, commands[name])
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	fs.PrintDefaults()
+}
+
+// headersFlag is a repeatable flag of "Key: Value" HTTP headers.
+type headersFlag []string
+
+func (h *headersFlag) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headersFlag) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("invalid header %q, expected \"Key: Value\"", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// rulesFlag is a repeatable flag of rules for the check command.
+type rulesFlag []string
+
+func (r *rulesFlag) String() string {
+	return strings.Join(*r, ", ")
+}
+
+func (r *rulesFlag) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+// tagFiltersFlag is a repeatable flag of filters on the samples labels.
+type tagFiltersFlag []tagFilter
+
+func (t *tagFiltersFlag) String() string {
+	var filters []string
+	for _, f := range *t {
+		filters = append(filters, f.String())
+	}
+	return strings.Join(filters, ", ")
+}
+
+func (t *tagFiltersFlag) Set(value string) error {
+	f, err := parseTagFilter(value)
+	if err != nil {
+		return err
+	}
+	*t = append(*t, f)
+	return nil
+}
+
+// pruneRulesFlag is a repeatable flag of regexps adding
+// prune rules of the given kind.
+type pruneRulesFlag struct {
+	kind  pruneKind
+	rules *[]pruneRule
+}
+
+func (p pruneRulesFlag) String() string {
+	if p.rules == nil {
+		return ""
+	}
+	var exprs []string
+	for _, r := range *p.rules {
+		if r.kind == p.kind {
+			exprs = append(exprs, r.re.String())
+		}
+	}
+	return strings.Join(exprs, ", ")
+}
+
+func (p pruneRulesFlag) Set(value string) error {
+	r, err := newPruneRule(p.kind, value)
+	if err != nil {
+		return err
+	}
+	*p.rules = append(*p.rules, r)
+	return nil
 }
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"hash/fnv"
+	"image/color"
+)
+
+// flameFrame is a frame of a flame graph: a node of the tree
+// positioned in the graph.
+type flameFrame struct {
+	node *treeNode
+	// IDs of the nodes from the root to this node, used to zoom on it
+	path []string
+
+	depth int
+	// x and width are fractions of the graph width
+	x     float64
+	width float64
+}
+
+// flameGraphLayout positions the nodes of the tree in a flame graph, zoomed
+// on the node of the given path. The ancestors of the zoomed node take the
+// whole width. Frames narrower than minWidth are not returned.
+// maxDepth is the depth of the deepest returned frame, the root being 0.
+func flameGraphLayout(tree *FunctionsTree, zoom []string, lineNumber, diff bool, minWidth float64) (frames []flameFrame, maxDepth int) {
+	// the root and the ancestors of the zoomed node
+	// ----------------------
+
+	node := tree.root
+	frames = append(frames, flameFrame{node: node, width: 1})
+
+	var path []string
+	for _, id := range zoom {
+		var next *treeNode
+		for _, child := range node.children {
+			if child.ID(lineNumber) == id {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		node = next
+		path = append(path, id)
+		frames = append(frames, flameFrame{
+			node:  node,
+			path:  append([]string(nil), path...),
+			depth: len(path),
+			width: 1,
+		})
+	}
+
+	// the subtree of the zoomed node
+	// ----------------------
+
+	total := flameValue(node, diff)
+	if total <= 0 {
+		return frames, len(path)
+	}
+
+	maxDepth = len(path)
+
+	var layout func(n *treeNode, path []string, depth int, x float64)
+	layout = func(n *treeNode, path []string, depth int, x float64) {
+		for _, child := range n.children {
+			width := float64(flameValue(child, diff)) / float64(total)
+			if width < minWidth {
+				continue
+			}
+
+			childPath := append(append([]string(nil), path...), child.ID(lineNumber))
+			frames = append(frames, flameFrame{
+				node:  child,
+				path:  childPath,
+				depth: depth,
+				x:     x,
+				width: width,
+			})
+			if depth > maxDepth {
+				maxDepth = depth
+			}
+
+			layout(child, childPath, depth+1, x)
+			x += width
+		}
+	}
+	layout(node, path, len(path)+1, 0)
+
+	return frames, maxDepth
+}
+
+// flameValue returns the value used as the width of the node: in
+// differential view, the value in the new profile.
+func flameValue(n *treeNode, diff bool) int64 {
+	if diff {
+		return n.base + n.value
+	}
+	return n.value
+}
+
+// flameLabel returns the label of the frame.
+func flameLabel(f flameFrame) string {
+	if f.node.function.Name == "" {
+		return "all"
+	}
+	return f.node.function.Name
+}
+
+var colorSearchMatch = color.RGBA{R: 0xe6, G: 0x00, B: 0xe6, A: 0xff}
+
+// flameColor returns the color of a frame: a warm color derived from
+// the function name, or a red/green color depending on the delta in
+// differential view.
+func flameColor(f flameFrame, diff bool) color.RGBA {
+	if diff {
+		value := flameValue(f.node, diff)
+		if value == 0 || f.node.value == 0 {
+			return color.RGBA{R: 0xdd, G: 0xdd, B: 0xdd, A: 0xff}
+		}
+		// the more it changed, the more saturated
+		ratio := float64(abs(f.node.value)) / float64(value)
+		if ratio > 1 {
+			ratio = 1
+		}
+		fade := uint8(0xdd - ratio*0xaa)
+		if f.node.value > 0 {
+			return color.RGBA{R: 0xff, G: fade, B: fade, A: 0xff}
+		}
+		return color.RGBA{R: fade, G: 0xee, B: fade, A: 0xff}
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(f.node.function.Name))
+	v := h.Sum32()
+
+	return color.RGBA{
+		R: 205 + uint8(v%50),
+		G: uint8((v >> 8) % 230),
+		B: uint8((v >> 16) % 55),
+		A: 0xff,
+	}
+}
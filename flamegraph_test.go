@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// foldedTree builds the tree of the folded stacks, aggregated by function.
+func foldedTree(t *testing.T, folded, baseFolded string, opts TreeOptions) (*Profile, *FunctionsTree) {
+	t.Helper()
+
+	p, err := readFolded([]byte(folded))
+	if err != nil {
+		t.Fatalf("readFolded() error = %v", err)
+	}
+	profile, err := NewProfile(p, ModeDefault, nil)
+	if err != nil {
+		t.Fatalf("NewProfile() error = %v", err)
+	}
+	if baseFolded != "" {
+		base, err := readFolded([]byte(baseFolded))
+		if err != nil {
+			t.Fatalf("readFolded() error = %v", err)
+		}
+		if profile, err = NewProfileWithBase(p, base, ModeDefault, nil); err != nil {
+			t.Fatalf("NewProfileWithBase() error = %v", err)
+		}
+	}
+
+	opts.AggregateByFunction = true
+	return profile, profile.BuildTree("test", opts)
+}
+
+func TestFlameGraphLayout(t *testing.T) {
+	folded := "main;a;b 6\nmain;c 2\n"
+
+	type frame struct {
+		label    string
+		depth    int
+		x, width float64
+	}
+
+	tests := []struct {
+		name     string
+		base     string
+		zoom     []string
+		minWidth float64
+		want     []frame
+		maxDepth int
+	}{
+		{
+			name: "whole tree",
+			want: []frame{
+				{"all", 0, 0, 1},
+				{"main", 1, 0, 1},
+				{"a", 2, 0, 0.75},
+				{"b", 3, 0, 0.75},
+				{"c", 2, 0.75, 0.25},
+			},
+			maxDepth: 3,
+		},
+		{
+			name:     "narrow frames",
+			minWidth: 0.5,
+			want: []frame{
+				{"all", 0, 0, 1},
+				{"main", 1, 0, 1},
+				{"a", 2, 0, 0.75},
+				{"b", 3, 0, 0.75},
+			},
+			maxDepth: 3,
+		},
+		{
+			name: "zoomed",
+			zoom: []string{Function{Name: "main"}.String(false), Function{Name: "a"}.String(false)},
+			want: []frame{
+				{"all", 0, 0, 1},
+				{"main", 1, 0, 1},
+				{"a", 2, 0, 1},
+				{"b", 3, 0, 1},
+			},
+			maxDepth: 3,
+		},
+		{
+			name: "unknown zoom",
+			zoom: []string{Function{Name: "main"}.String(false), "unknown"},
+			want: []frame{
+				{"all", 0, 0, 1},
+				{"main", 1, 0, 1},
+				{"a", 2, 0, 0.75},
+				{"b", 3, 0, 0.75},
+				{"c", 2, 0.75, 0.25},
+			},
+			maxDepth: 3,
+		},
+		{
+			// the widths are the values in the new profile
+			name: "differential",
+			base: "main;a;b 2\nmain;c 6\n",
+			want: []frame{
+				{"all", 0, 0, 1},
+				{"main", 1, 0, 1},
+				{"a", 2, 0, 0.75},
+				{"b", 3, 0, 0.75},
+				{"c", 2, 0.75, 0.25},
+			},
+			maxDepth: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, tree := foldedTree(t, folded, test.base, TreeOptions{})
+
+			frames, maxDepth := flameGraphLayout(tree, test.zoom, false, test.base != "", test.minWidth)
+			if maxDepth != test.maxDepth {
+				t.Errorf("maxDepth = %d, want %d", maxDepth, test.maxDepth)
+			}
+			if len(frames) != len(test.want) {
+				t.Fatalf("got %d frames, want %d", len(frames), len(test.want))
+			}
+			for i, f := range frames {
+				got := frame{flameLabel(f), f.depth, f.x, f.width}
+				want := test.want[i]
+				if got.label != want.label || got.depth != want.depth ||
+					math.Abs(got.x-want.x) > 1e-9 || math.Abs(got.width-want.width) > 1e-9 {
+					t.Errorf("frame %d = %+v, want %+v", i, got, want)
+				}
+				if len(f.path) != f.depth || (f.depth > 0 && f.path[f.depth-1] != f.node.ID(false)) {
+					t.Errorf("frame %d path = %v", i, f.path)
+				}
+			}
+		})
+	}
+}
+
+func TestFlameGraphLayoutEmpty(t *testing.T) {
+	tree := NewFunctionsTree("empty")
+	frames, maxDepth := flameGraphLayout(tree, nil, false, false, 0)
+	if len(frames) != 1 || maxDepth != 0 || !strings.EqualFold(flameLabel(frames[0]), "all") {
+		t.Errorf("flameGraphLayout() = %d frames, max depth %d, want the root only", len(frames), maxDepth)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// foldedLine is a line of the folded stacks format from Brendan Gregg's
+// FlameGraph tools: the frames from the root separated by semicolons, and
+// the value. The differential format has the base value before the value.
+var foldedLine = regexp.MustCompile(`^(.+?) (-?\d+)(?: (-?\d+))?$`)
+
+// looksLikeFolded returns true if the first lines of the data
+// are in the folded stacks format.
+func looksLikeFolded(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+
+	lines := 0
+	for scanner.Scan() && lines < 10 {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !foldedLine.MatchString(line) {
+			return false
+		}
+		lines++
+	}
+	return lines > 0
+}
+
+// readFolded converts folded stacks (e.g. "main;foo;bar 12") to a pprof
+// profile with a single "samples" sample type. For the differential
+// format, the second value is used.
+func readFolded(data []byte) (*pprof.Profile, error) {
+	b := newProfileBuilder()
+	b.profile.SampleType = []*pprof.ValueType{b.valueType("samples", "count")}
+	b.profile.PeriodType = b.valueType("samples", "count")
+	b.profile.Period = 1
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16*1024*1024)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		matches := foldedLine.FindStringSubmatch(line)
+		if matches == nil {
+			return nil, fmt.Errorf("readFolded: line %d: invalid folded stack: %q", lineNumber, line)
+		}
+
+		valueField := matches[2]
+		if matches[3] != "" {
+			valueField = matches[3]
+		}
+		value, err := strconv.ParseInt(valueField, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("readFolded: line %d: %v", lineNumber, err)
+		}
+
+		// pprof stores the leaf first
+		frames := strings.Split(matches[1], ";")
+		sample := &pprof.Sample{Value: []int64{value}}
+		for i := len(frames) - 1; i >= 0; i-- {
+			sample.LocationId = append(sample.LocationId, b.frame(frames[i], "", 0))
+		}
+		b.profile.Sample = append(b.profile.Sample, sample)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("readFolded: %v", err)
+	}
+
+	return b.profile, nil
+}
+
+// writeFolded writes the samples of the profile as folded stacks, with the
+// given options. In differential view, the base value is written before
+// the value as the FlameGraph difffolded.pl tool does.
+func writeFolded(w io.Writer, profile *Profile, opts TreeOptions) error {
+	type values struct{ base, value int64 }
+	stacks := make(map[string]*values)
+
+	add := func(s Sample, base bool) {
+		functions, ok := opts.stack(s)
+		if !ok || !stackMatches(functions, opts.SearchField) {
+			return
+		}
+
+		frames := make([]string, len(functions))
+		for i, f := range functions {
+			frames[i] = f.Name
+			if !opts.AggregateByFunction {
+				frames[i] = fmt.Sprintf("%s:%d", f.Name, f.LineNumber)
+			}
+		}
+
+		key := strings.Join(frames, ";")
+		v, ok := stacks[key]
+		if !ok {
+			v = &values{}
+			stacks[key] = v
+		}
+		if base {
+			v.base += s.Value
+		} else {
+			v.value += s.Value
+		}
+	}
+
+	for _, s := range profile.Samples {
+		add(s, false)
+	}
+	if profile.Base != nil {
+		for _, s := range profile.Base.Samples {
+			add(s, true)
+		}
+	}
+
+	keys := make([]string, 0, len(stacks))
+	for key := range stacks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	b := bufio.NewWriter(w)
+	for _, key := range keys {
+		if profile.Base != nil {
+			fmt.Fprintf(b, "%s %d %d\n", key, stacks[key].base, stacks[key].value)
+		} else {
+			fmt.Fprintf(b, "%s %d\n", key, stacks[key].value)
+		}
+	}
+
+	return b.Flush()
+}
+
+// stackMatches returns true if one of the functions matches the search.
+func stackMatches(functions []Function, searchField string) bool {
+	if searchField == "" {
+		return true
+	}
+	search := strings.ToLower(searchField)
+	for _, f := range functions {
+		if strings.Contains(strings.ToLower(f.Name), search) || strings.Contains(strings.ToLower(f.File), search) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// foldedStacks reads the profile with the given mode and returns the value
+// of each of its stacks, the frames from the root separated by semicolons.
+// The stacks without value for this mode are left out.
+func foldedStacks(t *testing.T, p *pprof.Profile, mode sampleMode) map[string]int64 {
+	t.Helper()
+
+	profile, err := NewProfile(p, mode, nil)
+	if err != nil {
+		t.Fatalf("NewProfile: %v", err)
+	}
+
+	rv := make(map[string]int64)
+	for _, s := range profile.Samples {
+		if s.Value == 0 {
+			continue
+		}
+		names := make([]string, len(s.Functions))
+		for i, f := range s.Functions {
+			names[i] = f.Name
+		}
+		rv[strings.Join(names, ";")] += s.Value
+	}
+	return rv
+}
+
+// equalStacks reports the differences between the stacks and the wanted ones.
+func equalStacks(t *testing.T, got, want map[string]int64) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("got %d stacks %v, want %d %v", len(got), got, len(want), want)
+	}
+	for stack, value := range want {
+		if got[stack] != value {
+			t.Errorf("stack %q = %d, want %d", stack, got[stack], value)
+		}
+	}
+}
+
+func TestLooksLikeFolded(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{"main;foo;bar 12\nmain;foo 3\n", true},
+		{"\nmain;foo 1 2\n", true},
+		{"main 1", true},
+		{"main;foo;bar\n", false},
+		{"main;foo 12\nnot folded\n", false},
+		{"", false},
+		{"{\"nodes\": []}", false},
+	}
+
+	for _, test := range tests {
+		if got := looksLikeFolded([]byte(test.data)); got != test.want {
+			t.Errorf("looksLikeFolded(%q) = %v, want %v", test.data, got, test.want)
+		}
+	}
+}
+
+func TestReadFolded(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[string]int64
+		err  string
+	}{
+		{
+			name: "stacks",
+			data: "main;foo;bar 12\nmain;foo 3\n\nmain;foo;bar 5\n",
+			want: map[string]int64{"main;foo;bar": 17, "main;foo": 3},
+		},
+		{
+			name: "frames with spaces",
+			data: "main;std::vector<int>::push_back (inlined) 2\n",
+			want: map[string]int64{"main;std::vector<int>::push_back (inlined)": 2},
+		},
+		{
+			name: "differential",
+			data: "main;foo 10 4\nmain;bar 0 6\n",
+			want: map[string]int64{"main;foo": 4, "main;bar": 6},
+		},
+		{
+			name: "invalid line",
+			data: "main;foo 1\nmain;bar\n",
+			err:  "line 2: invalid folded stack",
+		},
+		{
+			name: "value out of range",
+			data: "main 99999999999999999999\n",
+			err:  "line 1: strconv.ParseInt",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := readFolded([]byte(test.data))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("readFolded() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readFolded() error = %v", err)
+			}
+			equalStacks(t, foldedStacks(t, p, ModeDefault), test.want)
+		})
+	}
+}
+
+func TestWriteFolded(t *testing.T) {
+	data := "main;foo;bar 12\nmain;foo 3\nmain;baz 5\n"
+	p, err := readFolded([]byte(data))
+	if err != nil {
+		t.Fatalf("readFolded() error = %v", err)
+	}
+	base, err := readFolded([]byte("main;foo;bar 10\nmain;qux 1\n"))
+	if err != nil {
+		t.Fatalf("readFolded() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		base *pprof.Profile
+		opts TreeOptions
+		want string
+	}{
+		{
+			name: "roundtrip",
+			want: "main;baz 5\nmain;foo 3\nmain;foo;bar 12\n",
+		},
+		{
+			name: "focus",
+			opts: TreeOptions{Focus: []Function{{Name: "foo"}}},
+			want: "foo 3\nfoo;bar 12\n",
+		},
+		{
+			name: "search",
+			opts: TreeOptions{SearchField: "BAZ"},
+			want: "main;baz 5\n",
+		},
+		{
+			name: "differential",
+			base: base,
+			want: "main;baz 0 5\nmain;foo 0 3\nmain;foo;bar 10 12\nmain;qux 1 0\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			profile, err := NewProfileWithBase(p, test.base, ModeDefault, nil)
+			if err != nil {
+				t.Fatalf("NewProfileWithBase() error = %v", err)
+			}
+
+			test.opts.AggregateByFunction = true
+			var buf bytes.Buffer
+			if err := writeFolded(&buf, profile, test.opts); err != nil {
+				t.Fatalf("writeFolded() error = %v", err)
+			}
+			if buf.String() != test.want {
+				t.Errorf("writeFolded() = %q, want %q", buf.String(), test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// durationUnits are the time units a pprof value can be expressed in.
+var durationUnits = map[string]time.Duration{
+	"nanoseconds":  time.Nanosecond,
+	"microseconds": time.Microsecond,
+	"milliseconds": time.Millisecond,
+	"seconds":      time.Second,
+}
+
+// bytesUnits are the memory units a pprof value can be expressed in.
+var bytesUnits = map[string]uint64{
+	"bytes":     1,
+	"kilobytes": 1 << 10,
+	"megabytes": 1 << 20,
+	"gigabytes": 1 << 30,
+}
+
+// formatValue formats the given value depending on its pprof unit,
+// e.g. "nanoseconds", "bytes" or "count". Unknown units are appended
+// to the value.
+func formatValue(value int64, unit string) string {
+	if d, ok := durationUnits[unit]; ok {
+		return (time.Duration(value) * d).String()
+	}
+
+	sign, abs := "", uint64(value)
+	if value < 0 {
+		sign, abs = "-", uint64(-value)
+	}
+
+	if b, ok := bytesUnits[unit]; ok {
+		return sign + humanize.IBytes(abs*b)
+	}
+
+	switch unit {
+	case "count", "":
+		return humanize.Comma(value)
+	default:
+		return fmt.Sprintf("%s %s", humanize.Comma(value), unit)
+	}
+}
+
+// formatDelta formats the given value as formatValue does,
+// with an explicit sign for positive values.
+func formatDelta(value int64, unit string) string {
+	if value > 0 {
+		return "+" + formatValue(value, unit)
+	}
+	return formatValue(value, unit)
+}
+
+// describeSampleType returns a human readable description
+// of what the sample type measures.
+func describeSampleType(st ValueType) string {
+	switch st.Type {
+	case "cpu":
+		return "sampling duration"
+	case "alloc_space":
+		return "allocated memory"
+	case "inuse_space":
+		return "in-use memory"
+	case "alloc_objects":
+		return "allocated objects"
+	case "inuse_objects":
+		return "in-use objects"
+	default:
+		return st.Type
+	}
+}
+
+// headerText returns the description of the tree built with the
+// given options: its totals, the base profile totals, ...
+func (p *Profile) headerText(treeName string, opts TreeOptions) string {
+	st := p.SampleType
+	text := fmt.Sprintf("%s - total %s: %s", treeName, describeSampleType(st), formatValue(int64(p.TotalSampling), st.Unit))
+	if opts.Inverted {
+		text += " - inverted"
+	}
+	if len(opts.Focus) > 0 {
+		text += fmt.Sprintf(" - focus: %s", opts.Focus[len(opts.Focus)-1].Name)
+	}
+	if opts.GroupBy != "" {
+		text += fmt.Sprintf(" - grouped by %s", opts.GroupBy)
+	}
+	if opts.CollapseRecursion {
+		text += " - recursion collapsed"
+	}
+	for _, f := range opts.TagFocus {
+		text += fmt.Sprintf(" - tag focus: %s", f)
+	}
+	for _, f := range opts.TagIgnore {
+		text += fmt.Sprintf(" - tag ignore: %s", f)
+	}
+	if base := p.Base; base != nil {
+		delta := int64(p.TotalSampling) - int64(base.TotalSampling)
+		change := "(new)"
+		if base.TotalSampling > 0 {
+			change = fmt.Sprintf("(%+.2f%%)", float64(delta)/float64(base.TotalSampling)*100.0)
+		}
+		text += fmt.Sprintf(" - base: %s - delta: %s %s", formatValue(int64(base.TotalSampling), st.Unit),
+			formatDelta(delta, st.Unit), change)
+	} else if p.CaptureDuration > 0 {
+		text += fmt.Sprintf(" - total capture duration %s", p.CaptureDuration.String())
+	}
+	return text
+}
+
+// pruneTexts describes the prune rules of the profile and the cost
+// of the samples which stacks they've pruned.
+func (p *Profile) pruneTexts() []string {
+	unit := p.SampleType.Unit
+	var rv []string
+	for _, r := range p.PruneRules {
+		var percent float64
+		if p.TotalSampling > 0 {
+			percent = float64(r.removed) / float64(p.TotalSampling) * 100.0
+		}
+		rv = append(rv, fmt.Sprintf("%s - frames pruned from %s (%.2f%%) of samples", r, formatValue(r.removed, unit), percent))
+	}
+	return rv
+}
+
+// nodeTexts returns the texts describing the node: its value, its self value,
+// a detailed tooltip and the line describing it in the tree.
+func (p *Profile) nodeTexts(node *treeNode, aggregateByFunction bool) (value string, self string, tooltip string, lineText string) {
+	unit := p.SampleType.Unit
+	value = formatValue(node.value, unit)
+	self = formatValue(node.self, unit)
+	tooltip = fmt.Sprintf("%s of %s\nself: %s", value, formatValue(int64(p.TotalSampling), unit), self)
+
+	if p.Base != nil {
+		value = formatDelta(node.value, unit)
+		self = formatDelta(node.self, unit)
+		if change, ok := node.percentChange(); ok {
+			value += fmt.Sprintf(" (%+.2f%%)", change)
+		} else {
+			value += " (new)"
+		}
+		tooltip = fmt.Sprintf("delta: %s\nbase: %s\nnew: %s\nself delta: %s", value,
+			formatValue(node.base, unit), formatValue(node.base+node.value, unit), self)
+	}
+	lineText = fmt.Sprintf("%s %s:%d - %s - self: %s", node.function.Name, fileBase(node.function.File), node.function.LineNumber, value, self)
+	if aggregateByFunction {
+		lineText = fmt.Sprintf("%s %s - %s - self: %s", node.function.Name, fileBase(node.function.File), value, self)
+	}
+	if node.recursion > 1 {
+		lineText += fmt.Sprintf(" - recursion depth: %d", node.recursion)
+		tooltip += fmt.Sprintf("\nrecursion depth: %d (collapsed)", node.recursion)
+	}
+	if p.HasObjectSizes && node.objects > 0 {
+		// in differential view, the average of the new profile
+		avg := formatValue(node.averageObjectSize(), "bytes")
+		lineText += fmt.Sprintf(" - avg: %s/object", avg)
+		tooltip += fmt.Sprintf("\naverage object size: %s (%s objects)", avg, formatValue(node.objects, "count"))
+		if p.Base != nil {
+			tooltip += " in the new profile"
+		}
+	}
+	return value, self, tooltip, lineText
+}
+
+// fileBase returns the base name of the file of a function, or an empty
+// string for the functions without file (e.g. imported from folded stacks).
+func fileBase(file string) string {
+	if file == "" {
+		return ""
+	}
+	return path.Base(file)
+}
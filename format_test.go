@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value int64
+		unit  string
+		want  string
+	}{
+		// durations
+		{1500000, "nanoseconds", "1.5ms"},
+		{0, "nanoseconds", "0s"},
+		{1500, "microseconds", "1.5ms"},
+		{250, "milliseconds", "250ms"},
+		{2, "seconds", "2s"},
+		{-1500000, "nanoseconds", "-1.5ms"},
+		// bytes
+		{512, "bytes", "512 B"},
+		{2048, "bytes", "2.0 KiB"},
+		{2, "kilobytes", "2.0 KiB"},
+		{3, "megabytes", "3.0 MiB"},
+		{1, "gigabytes", "1.0 GiB"},
+		{-2048, "bytes", "-2.0 KiB"},
+		// counts
+		{1234567, "count", "1,234,567"},
+		{42, "", "42"},
+		{-1234, "count", "-1,234"},
+		// custom units
+		{1200, "requests", "1,200 requests"},
+		{-3, "requests", "-3 requests"},
+	}
+
+	for _, test := range tests {
+		if got := formatValue(test.value, test.unit); got != test.want {
+			t.Errorf("formatValue(%d, %q) = %q, want %q", test.value, test.unit, got, test.want)
+		}
+	}
+}
+
+func TestFormatDelta(t *testing.T) {
+	tests := []struct {
+		value int64
+		unit  string
+		want  string
+	}{
+		{5, "count", "+5"},
+		{-5, "count", "-5"},
+		{0, "count", "0"},
+		{2048, "bytes", "+2.0 KiB"},
+		{-1500000, "nanoseconds", "-1.5ms"},
+	}
+
+	for _, test := range tests {
+		if got := formatDelta(test.value, test.unit); got != test.want {
+			t.Errorf("formatDelta(%d, %q) = %q, want %q", test.value, test.unit, got, test.want)
+		}
+	}
+}
@@ -8,6 +8,8 @@ require (
 	github.com/dustin/go-humanize v1.0.0
 	github.com/gogo/protobuf v1.3.2
 	github.com/golang/protobuf v1.3.3
+	github.com/klauspost/compress v1.15.15
+	golang.org/x/exp v0.0.0-20240719175910-8a7402abbf56
 )
 
 require (
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// goTraceHeader is the header of the Go execution traces, e.g. "go 1.22 trace",
+// the version being the one of the trace format.
+var goTraceHeader = regexp.MustCompile(`^go 1\.(\d+) trace\x00`)
+
+// goTraceCpuPeriod is the duration of a CPU sample of an execution trace,
+// the traces don't contain the profiling rate so the default one of
+// runtime/pprof is assumed.
+const goTraceCpuPeriod = 10 * time.Millisecond
+
+// looksLikeGoTrace returns true if the data is a Go execution trace.
+func looksLikeGoTrace(data []byte) bool {
+	if len(data) > 16 {
+		data = data[:16]
+	}
+	return goTraceHeader.Match(data)
+}
+
+// goTraceVersion returns the minor version of the format of the trace,
+// e.g. 23 for the traces of Go 1.23 and Go 1.24 sharing the same format.
+func goTraceVersion(data []byte) int {
+	if len(data) > 16 {
+		data = data[:16]
+	}
+	matches := goTraceHeader.FindSubmatch(data)
+	if matches == nil {
+		return 0
+	}
+	version, _ := strconv.Atoi(string(matches[1]))
+	return version
+}
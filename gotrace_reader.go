@@ -0,0 +1,158 @@
+//go:build go1.21
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/remeh/diago/pprof"
+	"golang.org/x/exp/trace"
+)
+
+// goTraceMaxVersion is the newest trace format read by readGoTrace.
+const goTraceMaxVersion = 23
+
+// goTraceProfile is a profile derived from the states of the goroutines of
+// an execution trace, as `go tool trace` does: the time spent by goroutines
+// in the given state, for the given reasons, by the stack entering it.
+type goTraceProfile struct {
+	sampleType string
+	state      trace.GoState
+	reason     func(reason string) bool
+}
+
+var goTraceProfiles = []goTraceProfile{
+	{"network", trace.GoWaiting, func(reason string) bool { return reason == "network" }},
+	{"sync", trace.GoWaiting, func(reason string) bool {
+		return strings.Contains(reason, "chan") || strings.Contains(reason, "sync") || strings.Contains(reason, "select")
+	}},
+	{"syscall", trace.GoSyscall, func(string) bool { return true }},
+	{"sched", trace.GoRunnable, func(string) bool { return true }},
+}
+
+// readGoTrace converts a Go execution trace to a pprof profile with one
+// sample type per derived profile: the on-CPU time of the CPU samples
+// recorded in the trace (if the CPU profiler was running during the
+// trace), the time spent waiting for the network, for synchronization
+// primitives (channels, mutexes, select...), in syscalls, and the
+// scheduler latency (time spent runnable before running).
+// The traces of Go 1.11 to 1.24 are supported.
+func readGoTrace(data []byte) (*pprof.Profile, error) {
+	// the reader of golang.org/x/exp/trace reads the formats up to the
+	// one of Go 1.23 and 1.24, the newer ones need a version of the
+	// module requiring a newer Go than the one of diago.
+	if version := goTraceVersion(data); version > goTraceMaxVersion {
+		return nil, fmt.Errorf("readGoTrace: the trace format of Go 1.%d isn't supported, only the traces of Go 1.11 to 1.24 are", version)
+	}
+
+	r, err := trace.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("readGoTrace: trace.NewReader: %v", err)
+	}
+
+	b := newProfileBuilder()
+	b.profile.SampleType = []*pprof.ValueType{b.valueType("cpu", "nanoseconds")}
+	for _, p := range goTraceProfiles {
+		b.profile.SampleType = append(b.profile.SampleType, b.valueType(p.sampleType, "nanoseconds"))
+	}
+
+	// the values of the samples, by stack
+	samples := make(map[string]*pprof.Sample)
+	add := func(stack trace.Stack, idx int, value int64) {
+		var locations []uint64
+		stack.Frames(func(frame trace.StackFrame) bool {
+			locations = append(locations, b.goTraceLocation(frame))
+			return true
+		})
+		// unlike cmd/trace which filters them out, the events without
+		// stack are kept for the totals to be the ones of the trace.
+		if len(locations) == 0 {
+			locations = []uint64{b.frame("(no stack)", "", 0)}
+		}
+		key := fmt.Sprint(locations)
+		s, ok := samples[key]
+		if !ok {
+			s = &pprof.Sample{LocationId: locations, Value: make([]int64, len(b.profile.SampleType))}
+			samples[key] = s
+			b.profile.Sample = append(b.profile.Sample, s)
+		}
+		s.Value[idx] += value
+	}
+
+	// the goroutines in one of the states of the derived profiles
+	type tracked struct {
+		start trace.Event
+		idx   int
+	}
+	tracking := make(map[trace.GoID]tracked)
+
+	var first, last trace.Time
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("readGoTrace: %v", err)
+		}
+
+		if first == 0 {
+			first = ev.Time()
+		}
+		last = ev.Time()
+
+		switch ev.Kind() {
+		case trace.EventStackSample:
+			add(ev.Stack(), 0, int64(goTraceCpuPeriod))
+		case trace.EventStateTransition:
+			st := ev.StateTransition()
+			if st.Resource.Kind != trace.ResourceGoroutine {
+				continue
+			}
+			id := st.Resource.Goroutine()
+			_, state := st.Goroutine()
+
+			if t, ok := tracking[id]; ok {
+				if state == goTraceProfiles[t.idx-1].state {
+					continue
+				}
+				delete(tracking, id)
+				add(t.start.Stack(), t.idx, int64(ev.Time().Sub(t.start.Time())))
+			}
+
+			for i, p := range goTraceProfiles {
+				if state == p.state && p.reason(st.Reason) {
+					tracking[id] = tracked{start: ev, idx: i + 1}
+					break
+				}
+			}
+		}
+	}
+
+	b.profile.DurationNanos = int64(last.Sub(first))
+	b.profile.PeriodType = b.valueType("cpu", "nanoseconds")
+	b.profile.Period = int64(goTraceCpuPeriod)
+
+	// open the first non-empty profile
+	for i, sampleType := range b.profile.SampleType {
+		var total int64
+		for _, s := range b.profile.Sample {
+			total += s.Value[i]
+		}
+		if total > 0 {
+			b.profile.DefaultSampleType = sampleType.Type
+			break
+		}
+	}
+
+	return b.profile, nil
+}
+
+// goTraceLocation returns the ID of the location of a frame of a trace.
+func (b *profileBuilder) goTraceLocation(frame trace.StackFrame) uint64 {
+	functionID := b.function(frame.Func, frame.Func, frame.File, 0)
+	return b.location(0, frame.PC, []*pprof.Line{{FunctionId: functionID, Line: int64(frame.Line)}})
+}
@@ -0,0 +1,115 @@
+//go:build go1.21
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLooksLikeGoTrace(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{"go 1.23 trace\x00\x00\x00", true},
+		{"go 1.11 trace\x00\x00\x00\x00\x01", true},
+		{"go 1.23 trace", false},
+		{"go 2.0 trace\x00", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := looksLikeGoTrace([]byte(test.data)); got != test.want {
+			t.Errorf("looksLikeGoTrace(%q) = %v, want %v", test.data, got, test.want)
+		}
+	}
+}
+
+func TestGoTraceVersion(t *testing.T) {
+	tests := []struct {
+		data string
+		want int
+	}{
+		{"go 1.11 trace\x00\x00\x00\x00", 11},
+		{"go 1.23 trace\x00\x00\x00", 23},
+		{"go 1.26 trace\x00\x00\x00", 26},
+		{"go 1.26 trace", 0},
+		{"main;foo 1", 0},
+	}
+
+	for _, test := range tests {
+		if got := goTraceVersion([]byte(test.data)); got != test.want {
+			t.Errorf("goTraceVersion(%q) = %d, want %d", test.data, got, test.want)
+		}
+	}
+}
+
+func TestReadGoTraceUnsupportedVersion(t *testing.T) {
+	for _, header := range []string{"go 1.25 trace\x00\x00\x00", "go 1.26 trace\x00\x00\x00"} {
+		_, err := readGoTrace([]byte(header + "\x01\x02\x03"))
+		if err == nil || !strings.Contains(err.Error(), "only the traces of Go 1.11 to 1.24 are") {
+			t.Errorf("readGoTrace(%q) error = %v, want the supported versions", header, err)
+		}
+	}
+}
+
+// testdata/go1.24.trace is the trace of a program computing in main.compute
+// with the CPU profiler running, and sending values on a channel to two
+// goroutines receiving them in main.consumer.
+func TestReadGoTrace(t *testing.T) {
+	data, err := os.ReadFile("testdata/go1.24.trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := readGoTrace(data)
+	if err != nil {
+		t.Fatalf("readGoTrace() error = %v", err)
+	}
+	if typ := ReadProfileType(p); typ != "cpu" {
+		t.Errorf("ReadProfileType() = %q, want %q", typ, "cpu")
+	}
+
+	tests := []struct {
+		sampleType string
+		// a stack with a value for this sample type, the frames from
+		// the root separated by semicolons.
+		stack string
+	}{
+		{"cpu", "runtime.goexit;runtime.main;main.main;main.compute"},
+		{"sync", "main.consumer;runtime.chanrecv2"},
+		{"sched", "main.main;runtime.chansend1"},
+		{"sched", "(no stack)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.sampleType+" "+test.stack, func(t *testing.T) {
+			stacks := foldedStacks(t, p, sampleMode(test.sampleType))
+			if stacks[test.stack] <= 0 {
+				t.Errorf("stack %q = %d, want > 0", test.stack, stacks[test.stack])
+			}
+		})
+	}
+
+	// all the time of the trace is kept, the events
+	// without stack included.
+	for i, st := range p.SampleType {
+		sampleType := p.StringTable[st.Type]
+		var want int64
+		for _, s := range p.Sample {
+			want += s.Value[i]
+		}
+		var got int64
+		for stack, value := range foldedStacks(t, p, sampleMode(sampleType)) {
+			if strings.TrimSpace(stack) == "" {
+				t.Errorf("%s: empty stack", sampleType)
+			}
+			got += value
+		}
+		if got != want {
+			t.Errorf("%s: total = %d, want %d", sampleType, got, want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !go1.21
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// readGoTrace can't read the execution traces, the golang.org/x/exp/trace
+// package needs Go >= 1.21.
+func readGoTrace(data []byte) (*pprof.Profile, error) {
+	return nil, fmt.Errorf("readGoTrace: reading the Go execution traces needs diago to be built with Go >= 1.21")
+}
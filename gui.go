@@ -2,62 +2,87 @@ package main
 
 import (
 	"fmt"
+	"image/color"
+	"math"
 	"os"
-	"path"
-	"time"
 
 	"github.com/AllenDang/giu"
 	"github.com/AllenDang/imgui-go"
-	"github.com/dustin/go-humanize"
 
 	"github.com/remeh/diago/pprof"
 )
 
 type GUI struct {
 	// data
-	pprofProfile *pprof.Profile
-	profile      *Profile
-	tree         *FunctionsTree
+	pprofProfile     *pprof.Profile
+	basePprofProfile *pprof.Profile // nil if not in differential view
+	profile          *Profile
+	tree             *FunctionsTree
+	top              []topEntry
 
 	// ui options
-	mode                sampleMode
-	searchField         string
-	aggregateByFunction bool
+	mode    sampleMode
+	options TreeOptions
+	topSort topSort
+
+	// node on which the context menu has been opened
+	menuNode     *treeNode
+	openNodeMenu bool
+
+	// callers and callees of the selected function
+	butterfly          *butterfly
+	butterflyHistory   []Function
+	selectButterflyTab bool
+
+	// flame graph options
+	flameZoom []string
+	icicle    bool
+
+	// label browser, tag filters and grouping
+	labels        []labelKey
+	tagFilterText string
+	tagFilterErr  error
+	groupByIdx    int32
+
+	// allocation sizes of the whole profile or of the selected node
+	histogram          []sizeBucket
+	histogramPath      []Function
+	hasSizes           bool
+	selectHistogramTab bool
+
+	// user rules pruning the stacks
+	pruneRules []pruneRule
+	pruneText  string
+	pruneErr   error
 }
 
-type sampleMode string
-
+// colors of the progress bars in differential view
 var (
-	// use this when you don't really know the mode
-	// to use to read the profile.
-	ModeDefault   sampleMode = ""
-	ModeCpu       sampleMode = "cpu"
-	ModeHeapAlloc sampleMode = "heap-alloc"
-	ModeHeapInuse sampleMode = "heap-inuse"
+	colorGrowth = color.RGBA{R: 0xd0, G: 0x30, B: 0x30, A: 0xff}
+	colorShrink = color.RGBA{R: 0x30, G: 0xa0, B: 0x40, A: 0xff}
 )
 
-func NewGUI(profile *pprof.Profile) *GUI {
+// NewGUI creates the GUI displaying the given profile, or its differences
+// with the base profile if base is not nil.
+func NewGUI(profile *pprof.Profile, base *pprof.Profile) *GUI {
 	// init the base GUI object and load the profile
 	// ----------------------
 
 	g := &GUI{
-		pprofProfile:        profile,
-		aggregateByFunction: true,
+		pprofProfile:     profile,
+		basePprofProfile: base,
+		mode:             sampleMode(config.SampleType),
+		topSort:          TopSortFlat,
+		options:          config.TreeOptions(),
+		pruneRules:       config.PruneRules,
 	}
 	g.reloadProfile()
 
-	// depending on the profile opened, switch the either the ModeCpu
-	// or the ModeHeapAlloc.
+	// the default mode has been resolved to the default
+	// sample type of the profile.
 	// ----------------------
 
-	switch ReadProfileType(profile) {
-	case "space":
-		g.mode = ModeHeapAlloc
-	case "cpu":
-		g.mode = ModeCpu
-	default:
-		g.mode = ModeDefault
-	}
+	g.mode = sampleMode(g.profile.SampleType.Type)
 
 	return g
 }
@@ -71,44 +96,161 @@ func (g *GUI) onAggregationClick() {
 	g.reloadProfile()
 }
 
-func (g *GUI) onAllocated() {
-	g.mode = ModeHeapAlloc
-	g.reloadProfile()
+func (g *GUI) onInvertedClick() {
+	g.rebuildViews()
 }
 
-func (g *GUI) onInuse() {
-	g.mode = ModeHeapInuse
-	g.reloadProfile()
+func (g *GUI) onCollapseRecursionClick() {
+	g.rebuildViews()
+}
+
+func (g *GUI) onSampleType(mode sampleMode) func() {
+	return func() {
+		g.mode = mode
+		g.reloadProfile()
+	}
 }
 
 func (g *GUI) onSearch() {
-	g.tree = g.profile.BuildTree(config.File, g.aggregateByFunction, g.searchField)
+	g.rebuildViews()
 }
 
 func (g *GUI) reloadProfile() {
 	// read the pprof profile
 	// ----------------------
 
-	profile, err := NewProfile(g.pprofProfile, g.mode)
+	profile, err := NewProfileWithBase(g.pprofProfile, g.basePprofProfile, g.mode, g.pruneRules)
 	if err != nil {
 		fmt.Println("err:", err)
 		os.Exit(-1)
 	}
+
 	g.profile = profile
 
 	// rebuild the displayed tree
 	// ----------------------
 
-	g.tree = profile.BuildTree(config.File, g.aggregateByFunction, g.searchField)
+	g.rebuildViews()
+}
+
+// rebuildViews rebuilds the tree and the top table from the
+// current profile and options.
+func (g *GUI) rebuildViews() {
+	g.tree = g.profile.BuildTree(config.Source(), g.options)
+	g.top = g.profile.Top(g.options, g.topSort)
+	g.labels = g.profile.Labels(g.options)
+	g.histogram, g.hasSizes = g.profile.SizeHistogram(g.histogramPath, g.options)
+	if g.butterfly != nil {
+		b := g.profile.Butterfly(g.butterfly.function, g.options)
+		g.butterfly = &b
+	}
 }
 
 func (g *GUI) windowLoop() {
+	// select the callers/callees tab when a function
+	// has been selected from another tab.
+	butterflyFlags := giu.TabItemFlagsNone
+	if g.selectButterflyTab {
+		butterflyFlags = giu.TabItemFlagsSetSelected
+		g.selectButterflyTab = false
+	}
+	histogramFlags := giu.TabItemFlagsNone
+	if g.selectHistogramTab {
+		histogramFlags = giu.TabItemFlagsSetSelected
+		g.selectHistogramTab = false
+	}
+
 	giu.SingleWindow().Layout(
 		g.toolbox(),
-		g.treeFromFunctionsTree(g.tree),
+		giu.TabBar().TabItems(
+			giu.TabItem("Tree").Layout(
+				g.prunePanel(),
+				g.breadcrumb(),
+				g.treeFromFunctionsTree(g.tree),
+			),
+			giu.TabItem("Flame graph").Layout(g.flameGraph()),
+			giu.TabItem("Top").Layout(g.topTable()),
+			giu.TabItem("Callers/Callees").Flags(butterflyFlags).Layout(g.butterflyPanel()),
+			giu.TabItem("Labels").Layout(g.labelsPanel()),
+			giu.TabItem("Allocation sizes").Flags(histogramFlags).Layout(g.histogramPanel()),
+		),
+		giu.Custom(g.nodeMenu),
 	)
 }
 
+// nodeMenu builds the context menu of the tree nodes. It is opened from
+// the window level to not depend on the ID stack of the tree nodes.
+func (g *GUI) nodeMenu() {
+	if g.openNodeMenu {
+		giu.OpenPopup("node-menu")
+		g.openNodeMenu = false
+	}
+
+	if g.menuNode == nil {
+		return
+	}
+
+	f := g.menuNode.function
+	giu.Popup("node-menu").Layout(
+		giu.Label(f.Name),
+		giu.Separator(),
+		giu.MenuItem("Focus on this function").OnClick(g.onFocus(f)),
+		giu.MenuItem("Show callers and callees").OnClick(g.onButterfly(f)),
+		giu.MenuItem("Show allocation sizes").Enabled(g.hasSizes).OnClick(g.onNodeHistogram(g.menuNode)),
+	).Build()
+}
+
+// onNodeEvent handles the events on the tree nodes, right click
+// opens the context menu of the node.
+func (g *GUI) onNodeEvent(node *treeNode) func() {
+	return func() {
+		if giu.IsItemClicked(giu.MouseButtonRight) {
+			g.menuNode = node
+			g.openNodeMenu = true
+		}
+	}
+}
+
+// onFocus re-roots the tree at the given function.
+func (g *GUI) onFocus(f Function) func() {
+	return func() {
+		f.Self = 0
+		g.options.Focus = append(g.options.Focus, f)
+		g.rebuildViews()
+	}
+}
+
+// onUnfocus goes back to the given level of the focus path,
+// 0 being the full tree.
+func (g *GUI) onUnfocus(level int) func() {
+	return func() {
+		g.options.Focus = g.options.Focus[:level]
+		g.rebuildViews()
+	}
+}
+
+// breadcrumb returns the focus path, each level can be clicked
+// to return to it.
+func (g *GUI) breadcrumb() giu.Widget {
+	if len(g.options.Focus) == 0 {
+		return giu.Dummy(0, 0)
+	}
+
+	widgets := []giu.Widget{
+		giu.Button("Full tree").OnClick(g.onUnfocus(0)),
+	}
+	for i, f := range g.options.Focus {
+		widgets = append(widgets, giu.Label(">"))
+		if i == len(g.options.Focus)-1 {
+			widgets = append(widgets, giu.Label(f.Name))
+			continue
+		}
+		widgets = append(widgets, giu.Button(fmt.Sprintf("%s##focus%d", f.Name, i)).OnClick(g.onUnfocus(i+1)))
+	}
+
+	return giu.Row(widgets...)
+}
+
 func (g *GUI) toolbox() *giu.RowWidget {
 	size := giu.Context.GetPlatform().DisplaySize()
 
@@ -117,24 +259,40 @@ func (g *GUI) toolbox() *giu.RowWidget {
 	// search bar
 	// ----------------------
 
-	filterText := giu.InputText(&g.searchField).Flags(imgui.InputTextFlagsCallbackAlways).Label("Filter...").OnChange(g.onSearch).Size(size[0] / 4)
+	filterText := giu.InputText(&g.options.SearchField).Flags(imgui.InputTextFlagsCallbackAlways).Label("Filter...").OnChange(g.onSearch).Size(size[0] / 4)
 
 	widgets = append(widgets, filterText)
 
 	// aggregate per func option
 	// ----------------------
 	widgets = append(widgets,
-		giu.Checkbox("aggregate by functions", &g.aggregateByFunction).OnChange(g.onAggregationClick))
+		giu.Checkbox("aggregate by functions", &g.options.AggregateByFunction).OnChange(g.onAggregationClick))
 	widgets = append(widgets,
 		giu.Tooltip("By default, Diago aggregates by functions, uncheck to have the information up to the lines of code"))
 
-	// in heap mode, offer the two modes
+	// inverted tree option
+	// ----------------------
+	widgets = append(widgets,
+		giu.Checkbox("inverted", &g.options.Inverted).OnChange(g.onInvertedClick))
+	widgets = append(widgets,
+		giu.Tooltip("Build the tree from the leaves: the top level lists the functions by self cost, expanding them shows their callers"))
+
+	// collapse recursion option
 	// ----------------------
-	if g.mode == ModeHeapAlloc || g.mode == ModeHeapInuse {
-		widgets = append(widgets,
-			giu.RadioButton("allocated", g.mode == ModeHeapAlloc).OnChange(g.onAllocated))
-		widgets = append(widgets,
-			giu.RadioButton("inuse", g.mode == ModeHeapInuse).OnChange(g.onInuse))
+	widgets = append(widgets,
+		giu.Checkbox("collapse recursion", &g.options.CollapseRecursion).OnChange(g.onCollapseRecursionClick))
+	widgets = append(widgets,
+		giu.Tooltip("Collapse the recursive calls of a function, direct or indirect, in a single node"))
+
+	// offer every sample type available in the profile,
+	// e.g. allocated or in-use memory for heap profiles
+	// ----------------------
+	if len(g.profile.SampleTypes) > 1 {
+		for _, st := range g.profile.SampleTypes {
+			mode := sampleMode(st.Type)
+			widgets = append(widgets,
+				giu.RadioButton(describeSampleType(st), g.mode == mode).OnChange(g.onSampleType(mode)))
+		}
 	}
 
 	return giu.Row(
@@ -146,15 +304,7 @@ func (g *GUI) treeFromFunctionsTree(tree *FunctionsTree) giu.Layout {
 	// generate the header
 	// ----------------------
 
-	var text string
-	switch g.mode {
-	case ModeCpu:
-		text = fmt.Sprintf("%s - total sampling duration: %s - total capture duration %s", tree.name, time.Duration(g.profile.TotalSampling).String(), g.profile.CaptureDuration.String())
-	case ModeHeapAlloc:
-		text = fmt.Sprintf("%s - total allocated memory: %s", tree.name, humanize.IBytes(g.profile.TotalSampling))
-	case ModeHeapInuse:
-		text = fmt.Sprintf("%s - total in-use memory: %s", tree.name, humanize.IBytes(g.profile.TotalSampling))
-	}
+	text := g.profile.headerText(tree.name, g.options)
 
 	// start generating the tree
 	// ----------------------
@@ -188,10 +338,15 @@ func (g *GUI) treeNodeFromFunctionsTreeNode(node *treeNode) giu.Layout {
 		// append the line to the tree
 		// ----------------------
 
+		var progressBar giu.Widget = giu.ProgressBar(float32(child.percent)/100).Size(90, 0).Overlayf("%.3f%%", child.percent)
+		if g.profile.Base != nil {
+			progressBar = g.diffProgressBar(child)
+		}
+
 		rv = append(rv, giu.Row(
-			giu.ProgressBar(float32(child.percent)/100).Size(90, 0).Overlayf("%.3f%%", child.percent),
+			progressBar,
 			giu.Tooltip(tooltip),
-			giu.TreeNode(lineText).Flags(flags).Layout(g.treeNodeFromFunctionsTreeNode(child)),
+			giu.TreeNode(lineText).Flags(flags).Event(g.onNodeEvent(child)).Layout(g.treeNodeFromFunctionsTreeNode(child)),
 		),
 		)
 	}
@@ -199,19 +354,19 @@ func (g *GUI) treeNodeFromFunctionsTreeNode(node *treeNode) giu.Layout {
 	return rv
 }
 
+// diffProgressBar returns a progress bar of the node delta, red if it
+// grew compared to the base profile, green if it shrank.
+func (g *GUI) diffProgressBar(node *treeNode) giu.Widget {
+	col := colorGrowth
+	if node.value < 0 {
+		col = colorShrink
+	}
+	fraction := math.Abs(node.percent) / 100
+	return giu.Style().SetColor(giu.StyleColorPlotHistogram, col).To(
+		giu.ProgressBar(float32(fraction)).Size(90, 0).Overlayf("%+.3f%%", node.percent),
+	)
+}
+
 func (g *GUI) texts(node *treeNode) (value string, self string, tooltip string, lineText string) {
-	if g.profile.Type == "cpu" {
-		value = time.Duration(node.value).String()
-		self = time.Duration(node.self).String()
-		tooltip = fmt.Sprintf("%s of %s\nself: %s", value, time.Duration(g.profile.TotalSampling).String(), self)
-	} else {
-		value = humanize.IBytes(uint64(node.value))
-		self = humanize.IBytes(uint64(node.self))
-		tooltip = fmt.Sprintf("%s of %s\nself: %s", value, humanize.IBytes(g.profile.TotalSampling), self)
-	}
-	lineText = fmt.Sprintf("%s %s:%d - %s - self: %s", node.function.Name, path.Base(node.function.File), node.function.LineNumber, value, self)
-	if g.aggregateByFunction {
-		lineText = fmt.Sprintf("%s %s - %s - self: %s", node.function.Name, path.Base(node.function.File), value, self)
-	}
-	return value, self, tooltip, lineText
+	return g.profile.nodeTexts(node, g.options.AggregateByFunction)
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/AllenDang/giu"
+)
+
+// butterflyPanel returns the callers and callees of the selected
+// function, clicking on one of them navigates to it.
+func (g *GUI) butterflyPanel() giu.Widget {
+	if g.butterfly == nil {
+		return giu.Label("Right click on a node of the tree or click on a function of the top table to see its callers and callees.")
+	}
+
+	b := g.butterfly
+	unit := g.profile.SampleType.Unit
+	format := formatValue
+	if g.profile.Base != nil {
+		format = formatDelta
+	}
+
+	// navigation
+	// ----------------------
+
+	var back giu.Widget = giu.Dummy(0, 0)
+	if len(g.butterflyHistory) > 0 {
+		back = giu.Button("Back").OnClick(g.onButterflyBack)
+	}
+
+	header := giu.Row(
+		back,
+		giu.Labelf("%s - cum: %s - flat: %s", g.functionName(b.function), format(b.cum, unit), format(b.flat, unit)),
+	)
+
+	// callers and callees tables
+	// ----------------------
+
+	table := func(id string, edges []butterflyEdge, empty string) giu.Widget {
+		rows := []*giu.TableRowWidget{
+			giu.TableRow(giu.Label("value"), giu.Label("%"), giu.Label("function")),
+		}
+		for _, e := range edges {
+			var name giu.Widget
+			if e.function.Name == "" {
+				name = giu.Label(empty)
+			} else {
+				name = giu.Selectable(fmt.Sprintf("%s##%s", g.functionName(e.function), id)).OnClick(g.onButterfly(e.function))
+			}
+			rows = append(rows, giu.TableRow(
+				giu.Label(format(e.value, unit)),
+				giu.Labelf("%.2f%%", e.percent),
+				name,
+			))
+		}
+		return giu.Table().ID(id).Freeze(0, 1).Size(-1, 250).Rows(rows...)
+	}
+
+	return giu.Layout{
+		header,
+		giu.Label("Callers"),
+		table("callers", b.callers, "(root)"),
+		giu.Label("Callees"),
+		table("callees", b.callees, "(self)"),
+	}
+}
+
+// functionName returns the name of the function as displayed in
+// the tables, with its line number if not aggregating by functions.
+func (g *GUI) functionName(f Function) string {
+	if g.options.AggregateByFunction {
+		return fmt.Sprintf("%s %s", f.Name, fileBase(f.File))
+	}
+	return fmt.Sprintf("%s %s:%d", f.Name, fileBase(f.File), f.LineNumber)
+}
+
+// onButterfly displays the callers and callees of the given function.
+func (g *GUI) onButterfly(f Function) func() {
+	return func() {
+		if g.butterfly != nil {
+			g.butterflyHistory = append(g.butterflyHistory, g.butterfly.function)
+		}
+		b := g.profile.Butterfly(f, g.options)
+		g.butterfly = &b
+		g.selectButterflyTab = true
+	}
+}
+
+func (g *GUI) onButterflyBack() {
+	f := g.butterflyHistory[len(g.butterflyHistory)-1]
+	g.butterflyHistory = g.butterflyHistory[:len(g.butterflyHistory)-1]
+	b := g.profile.Butterfly(f, g.options)
+	g.butterfly = &b
+}
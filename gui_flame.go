@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/AllenDang/giu"
+	"github.com/AllenDang/imgui-go"
+)
+
+const flameRowHeight = 18
+
+// flameGraph returns the flame graph of the tree with its options.
+func (g *GUI) flameGraph() giu.Layout {
+	var reset giu.Widget = giu.Dummy(0, 0)
+	if len(g.flameZoom) > 0 {
+		reset = giu.Button("Reset zoom").OnClick(g.onFlameZoom(nil))
+	}
+
+	return giu.Layout{
+		giu.Row(
+			giu.RadioButton("flame graph", !g.icicle).OnChange(g.onIcicle(false)),
+			giu.RadioButton("icicle graph", g.icicle).OnChange(g.onIcicle(true)),
+			reset,
+		),
+		giu.Custom(g.drawFlameGraph),
+	}
+}
+
+// drawFlameGraph draws the flame graph with the imgui draw list, clicking
+// on a frame zooms on it, right clicking opens the node context menu.
+func (g *GUI) drawFlameGraph() {
+	width, _ := giu.GetAvailableRegion()
+	if width <= 0 {
+		return
+	}
+
+	// layout the frames, hide the ones smaller than a pixel
+	// ----------------------
+
+	diff := g.profile.Base != nil
+	frames, maxDepth := flameGraphLayout(g.tree, g.flameZoom, !g.options.AggregateByFunction, diff, 1/float64(width))
+	height := float32((maxDepth + 1) * flameRowHeight)
+
+	origin := giu.GetCursorScreenPos()
+	charWidth, _ := giu.CalcTextSize("m")
+	canvas := giu.GetCanvas()
+
+	rect := func(f flameFrame) (image.Point, image.Point) {
+		y := f.depth * flameRowHeight
+		if !g.icicle {
+			y = (maxDepth - f.depth) * flameRowHeight
+		}
+		min := origin.Add(image.Pt(int(f.x*float64(width)), y))
+		max := origin.Add(image.Pt(int((f.x+f.width)*float64(width)), y+flameRowHeight-1))
+		return min, max
+	}
+
+	// draw the frames
+	// ----------------------
+
+	for _, f := range frames {
+		min, max := rect(f)
+
+		col := flameColor(f, diff)
+		if g.options.SearchField != "" && f.node.function.Name != "" && f.node.matches(g.options.SearchField) {
+			col = colorSearchMatch
+		}
+		canvas.AddRectFilled(min, max, col, 0, giu.DrawFlagsNone)
+
+		// only draw the label if there is room for a few chars
+		chars := int(float32(max.X-min.X-4) / charWidth)
+		if chars < 3 {
+			continue
+		}
+		label := flameLabel(f)
+		if len(label) > chars {
+			label = label[:chars-2] + ".."
+		}
+		canvas.AddText(min.Add(image.Pt(2, 1)), color.Black, label)
+	}
+
+	// handle the interactions with the graph
+	// ----------------------
+
+	giu.InvisibleButton().ID("flamegraph").Size(width, height).Build()
+	if !giu.IsItemHovered() {
+		return
+	}
+
+	mouse := giu.GetMousePos()
+	for _, f := range frames {
+		min, max := rect(f)
+		if !mouse.In(image.Rectangle{Min: min, Max: max.Add(image.Pt(1, 1))}) {
+			continue
+		}
+
+		_, _, tooltip, _ := g.texts(f.node)
+		imgui.SetTooltip(fmt.Sprintf("%s\n%s", flameLabel(f), tooltip))
+
+		switch {
+		case giu.IsMouseClicked(giu.MouseButtonLeft):
+			g.onFlameZoom(f.path)()
+		case giu.IsMouseClicked(giu.MouseButtonRight) && f.node.function.Name != "":
+			g.menuNode = f.node
+			g.openNodeMenu = true
+		}
+		break
+	}
+}
+
+// onFlameZoom zooms the flame graph on the node of the given path,
+// nil to display the whole graph.
+func (g *GUI) onFlameZoom(path []string) func() {
+	return func() {
+		g.flameZoom = path
+	}
+}
+
+func (g *GUI) onIcicle(icicle bool) func() {
+	return func() {
+		g.icicle = icicle
+	}
+}
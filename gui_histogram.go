@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/AllenDang/giu"
+)
+
+// histogramPanel returns the histogram of the allocation sizes of the
+// whole profile or of the node selected in the tree.
+func (g *GUI) histogramPanel() giu.Widget {
+	if !g.hasSizes {
+		return giu.Label("The samples don't have the size of the allocated objects (the bytes label of the Go heap profiles).")
+	}
+
+	unit := g.profile.SampleType.Unit
+
+	// what the histogram is computed for
+	// ----------------------
+
+	var header giu.Widget = giu.Label("Allocation sizes of the whole profile. Right click on a node of the tree to see its allocation sizes.")
+	if len(g.histogramPath) > 0 {
+		f := g.histogramPath[len(g.histogramPath)-1]
+		header = giu.Row(
+			giu.Button("Whole profile").OnClick(g.onHistogram(nil)),
+			giu.Labelf("Allocation sizes of %s", g.functionName(f)),
+		)
+	}
+
+	// the buckets
+	// ----------------------
+
+	var max int64
+	for _, b := range g.histogram {
+		if b.value > max {
+			max = b.value
+		}
+	}
+
+	rows := []*giu.TableRowWidget{
+		giu.TableRow(giu.Label("size"), giu.Label(""), giu.Label("value"), giu.Label("objects")),
+	}
+	for _, b := range g.histogram {
+		objects := ""
+		if g.profile.HasObjectSizes {
+			objects = formatValue(b.objects, "count")
+		}
+		rows = append(rows, giu.TableRow(
+			giu.Label(b.String()),
+			giu.ProgressBar(float32(b.value)/float32(max)).Size(200, 0).Overlayf("%.2f%%", b.percent),
+			giu.Label(formatValue(b.value, unit)),
+			giu.Label(objects),
+		))
+	}
+
+	return giu.Layout{
+		header,
+		giu.Table().ID("histogram").Freeze(0, 1).FastMode(true).Rows(rows...),
+	}
+}
+
+// onHistogram computes the histogram of the allocation sizes of the
+// node at the given path of the tree, of the whole profile if nil.
+func (g *GUI) onHistogram(path []Function) func() {
+	return func() {
+		g.histogramPath = path
+		g.histogram, g.hasSizes = g.profile.SizeHistogram(g.histogramPath, g.options)
+		g.selectHistogramTab = true
+	}
+}
+
+// onNodeHistogram computes the histogram of the allocation sizes
+// of the given node of the tree.
+func (g *GUI) onNodeHistogram(node *treeNode) func() {
+	return func() {
+		g.onHistogram(g.tree.pathTo(node))()
+	}
+}
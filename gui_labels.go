@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/AllenDang/giu"
+)
+
+// labelsPanel returns the label browser: the label keys of the samples,
+// the cost of each of their values, and the tag filters. Clicking on the
+// focus or ignore button of a value filters the samples by it.
+func (g *GUI) labelsPanel() giu.Widget {
+	unit := g.profile.SampleType.Unit
+	format := formatValue
+	if g.profile.Base != nil {
+		format = formatDelta
+	}
+
+	// group the tree by a label key
+	// ----------------------
+
+	groupBy := []string{"(none)"}
+	g.groupByIdx = 0
+	for _, k := range g.labels {
+		groupBy = append(groupBy, k.key)
+		if k.key == g.options.GroupBy {
+			g.groupByIdx = int32(len(groupBy) - 1)
+		}
+	}
+	if g.options.GroupBy != "" && g.groupByIdx == 0 {
+		// the key isn't in the filtered samples anymore
+		groupBy = append(groupBy, g.options.GroupBy)
+		g.groupByIdx = int32(len(groupBy) - 1)
+	}
+
+	// the tag filters
+	// ----------------------
+
+	layout := giu.Layout{
+		giu.Combo("group the tree by label", groupBy[g.groupByIdx], groupBy, &g.groupByIdx).Size(200).OnChange(g.onGroupBy(groupBy)),
+		giu.Row(
+			giu.InputText(&g.tagFilterText).Label("key=value, key~regex or key=min:max").Size(300),
+			giu.Button("Focus").OnClick(g.onTagFilterText(false)),
+			giu.Button("Ignore").OnClick(g.onTagFilterText(true)),
+		),
+	}
+	if g.tagFilterErr != nil {
+		layout = append(layout, giu.Label(g.tagFilterErr.Error()))
+	}
+
+	filters := func(kind string, filters []tagFilter, ignore bool) {
+		for i, f := range filters {
+			layout = append(layout, giu.Row(
+				giu.SmallButton(fmt.Sprintf("x##%s%d", kind, i)).OnClick(g.onRemoveTagFilter(ignore, i)),
+				giu.Labelf("%s: %s", kind, f),
+			))
+		}
+	}
+	filters("focus", g.options.TagFocus, false)
+	filters("ignore", g.options.TagIgnore, true)
+
+	// the labels and their values
+	// ----------------------
+
+	if len(g.labels) == 0 {
+		return append(layout, giu.Label("The samples don't have any label."))
+	}
+
+	for _, k := range g.labels {
+		rows := []*giu.TableRowWidget{
+			giu.TableRow(giu.Label("value"), giu.Label("%"), giu.Label("samples"), giu.Label(k.key), giu.Label("")),
+		}
+		for _, v := range k.values {
+			expr := fmt.Sprintf("%s=%s", k.key, v.label.rawValue())
+			rows = append(rows, giu.TableRow(
+				giu.Label(format(v.value, unit)),
+				giu.Labelf("%.2f%%", v.percent),
+				giu.Labelf("%d", v.samples),
+				giu.Label(v.label.Value()),
+				giu.Row(
+					giu.SmallButton("focus##"+expr).OnClick(g.onTagFilter(expr, false)),
+					giu.SmallButton("ignore##"+expr).OnClick(g.onTagFilter(expr, true)),
+				),
+			))
+		}
+
+		text := fmt.Sprintf("%s - %s (%.2f%%) - %d values", k.key, format(k.value, unit), k.percent, len(k.values))
+		layout = append(layout, giu.TreeNode(text).Flags(giu.TreeNodeFlagsFramed).Layout(
+			giu.Table().ID("labels-"+k.key).Freeze(0, 1).FastMode(true).Size(-1, 250).Rows(rows...),
+		))
+	}
+
+	return layout
+}
+
+// onGroupBy groups the tree by the selected label key.
+func (g *GUI) onGroupBy(keys []string) func() {
+	return func() {
+		g.options.GroupBy = ""
+		if g.groupByIdx > 0 {
+			g.options.GroupBy = keys[g.groupByIdx]
+		}
+		g.rebuildViews()
+	}
+}
+
+// onTagFilter adds the tag filter to the focus or ignore filters.
+func (g *GUI) onTagFilter(expr string, ignore bool) func() {
+	return func() {
+		f, err := parseTagFilter(expr)
+		g.tagFilterErr = err
+		if err != nil {
+			return
+		}
+
+		if ignore {
+			g.options.TagIgnore = append(g.options.TagIgnore, f)
+		} else {
+			g.options.TagFocus = append(g.options.TagFocus, f)
+		}
+		g.rebuildViews()
+	}
+}
+
+// onTagFilterText adds the tag filter typed by the user.
+func (g *GUI) onTagFilterText(ignore bool) func() {
+	return func() {
+		g.onTagFilter(g.tagFilterText, ignore)()
+		if g.tagFilterErr == nil {
+			g.tagFilterText = ""
+		}
+	}
+}
+
+func (g *GUI) onRemoveTagFilter(ignore bool, i int) func() {
+	return func() {
+		if ignore {
+			g.options.TagIgnore = append(g.options.TagIgnore[:i:i], g.options.TagIgnore[i+1:]...)
+		} else {
+			g.options.TagFocus = append(g.options.TagFocus[:i:i], g.options.TagFocus[i+1:]...)
+		}
+		g.rebuildViews()
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/AllenDang/giu"
+)
+
+// prunePanel returns the prune rules, the cost of the samples they've
+// pruned and the inputs to add new rules.
+func (g *GUI) prunePanel() giu.Widget {
+	title := "Pruned frames"
+	if len(g.profile.PruneRules) > 0 {
+		title = fmt.Sprintf("Pruned frames (%d rules)", len(g.profile.PruneRules))
+	}
+
+	layout := giu.Layout{
+		giu.Row(
+			giu.InputText(&g.pruneText).Label("regexp").Size(300),
+			giu.Button("Drop").OnClick(g.onPruneRule(PruneDrop)),
+			giu.Tooltip("Drop the matching frames and their callees, their cost is attributed to the caller"),
+			giu.Button("Ignore").OnClick(g.onPruneRule(PruneIgnore)),
+			giu.Tooltip("Hide the matching frames, their cost is attributed to their callers"),
+		),
+	}
+	if g.pruneErr != nil {
+		layout = append(layout, giu.Label(g.pruneErr.Error()))
+	}
+
+	// the drop_frames of the profile come first and can't be removed
+	profileRules := len(g.profile.PruneRules) - len(g.pruneRules)
+	for i, text := range g.profile.pruneTexts() {
+		var remove giu.Widget = giu.Dummy(0, 0)
+		if i >= profileRules {
+			remove = giu.SmallButton(fmt.Sprintf("x##prune%d", i)).OnClick(g.onRemovePruneRule(i - profileRules))
+		}
+		layout = append(layout, giu.Row(remove, giu.Label(text)))
+	}
+
+	return giu.TreeNode(title).Flags(giu.TreeNodeFlagsFramed).Layout(layout)
+}
+
+// onPruneRule adds the prune rule typed by the user.
+func (g *GUI) onPruneRule(kind pruneKind) func() {
+	return func() {
+		r, err := newPruneRule(kind, g.pruneText)
+		g.pruneErr = err
+		if err != nil {
+			return
+		}
+		g.pruneRules = append(g.pruneRules, r)
+		g.pruneText = ""
+		g.reloadProfile()
+	}
+}
+
+func (g *GUI) onRemovePruneRule(i int) func() {
+	return func() {
+		g.pruneRules = append(g.pruneRules[:i:i], g.pruneRules[i+1:]...)
+		g.reloadProfile()
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/AllenDang/giu"
+)
+
+// topTable returns the flat top table of the profile, one line
+// per function with its flat and cumulative values. Clicking on
+// a function shows its callers and callees.
+func (g *GUI) topTable() giu.Widget {
+	unit := g.profile.SampleType.Unit
+	format := formatValue
+	if g.profile.Base != nil {
+		format = formatDelta
+	}
+
+	// the header, click on a column to sort by it
+	// ----------------------
+
+	header := func(label string, sortBy topSort) giu.Widget {
+		if g.topSort == sortBy {
+			label += " v"
+		}
+		return giu.Selectable(label).OnClick(g.onTopSort(sortBy))
+	}
+
+	rows := []*giu.TableRowWidget{
+		giu.TableRow(
+			header("flat", TopSortFlat),
+			header("flat%", TopSortFlat),
+			header("sum%", TopSortFlat),
+			header("cum", TopSortCum),
+			header("cum%", TopSortCum),
+			header("function", TopSortName),
+		),
+	}
+
+	// the entries
+	// ----------------------
+
+	for _, e := range g.top {
+		rows = append(rows, giu.TableRow(
+			giu.Label(format(e.flat, unit)),
+			giu.Labelf("%.2f%%", e.flatPercent),
+			giu.Labelf("%.2f%%", e.sumPercent),
+			giu.Label(format(e.cum, unit)),
+			giu.Labelf("%.2f%%", e.cumPercent),
+			giu.Selectable(g.functionName(e.function)).OnClick(g.onButterfly(e.function)),
+		))
+	}
+
+	return giu.Table().Freeze(0, 1).FastMode(true).Rows(rows...)
+}
+
+func (g *GUI) onTopSort(sortBy topSort) func() {
+	return func() {
+		g.topSort = sortBy
+		g.top = g.profile.Top(g.options, g.topSort)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// goSizeClasses are the object sizes of the size classes of the Go
+// allocator (see runtime/sizeclasses.go). The larger objects have their
+// own spans, they are bucketed by powers of two.
+var goSizeClasses = []int64{
+	8, 16, 24, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224,
+	240, 256, 288, 320, 352, 384, 416, 448, 480, 512, 576, 640, 704, 768,
+	896, 1024, 1152, 1280, 1408, 1536, 1792, 2048, 2304, 2688, 3072, 3200,
+	3456, 4096, 4864, 5376, 6144, 6528, 6784, 6912, 8192, 9472, 9728, 10240,
+	10880, 12288, 13568, 14336, 16384, 18432, 19072, 20480, 21760, 24576,
+	27264, 28672, 32768,
+}
+
+// sizeLabel is the numeric label holding the size of the
+// allocated objects in the samples of the Go heap profiles.
+const sizeLabel = "bytes"
+
+// sizeBucket is a bucket of the allocation sizes histogram, holding
+// the objects which size is in ]min, max].
+type sizeBucket struct {
+	min, max int64
+	value    int64
+	objects  int64
+	percent  float64
+}
+
+func (b sizeBucket) String() string {
+	return fmt.Sprintf("%s - %s", formatValue(b.min, "bytes"), formatValue(b.max, "bytes"))
+}
+
+// sizeBucketBounds returns the bounds of the bucket of the given size.
+func sizeBucketBounds(size int64) (min, max int64) {
+	for _, class := range goSizeClasses {
+		if size <= class {
+			return min, class
+		}
+		min = class
+	}
+	for max = min * 2; size > max; max *= 2 {
+		min = max
+	}
+	return min, max
+}
+
+// SizeHistogram returns the histogram of the allocation sizes of the
+// samples under the node at the given path of the tree built with opts,
+// or of all the samples if path is empty. ok is false if the samples
+// don't have the size of the allocated objects as label.
+func (p *Profile) SizeHistogram(path []Function, opts TreeOptions) (buckets []sizeBucket, ok bool) {
+	byMax := make(map[int64]*sizeBucket)
+	var total int64
+
+	for _, s := range p.Samples {
+		var size int64
+		hasSize := false
+		for _, l := range s.Labels {
+			if l.Key == sizeLabel && l.IsNumeric() {
+				size, hasSize = l.Num, true
+				break
+			}
+		}
+		if !hasSize {
+			continue
+		}
+		ok = true
+
+		if s.Value == 0 || !underPath(s, path, opts) {
+			continue
+		}
+
+		min, max := sizeBucketBounds(size)
+		b, exists := byMax[max]
+		if !exists {
+			b = &sizeBucket{min: min, max: max}
+			byMax[max] = b
+		}
+		b.value += s.Value
+		b.objects += s.Objects
+		total += s.Value
+	}
+
+	for _, b := range byMax {
+		if total != 0 {
+			b.percent = float64(b.value) / float64(total) * 100.0
+		}
+		buckets = append(buckets, *b)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].max < buckets[j].max
+	})
+
+	return buckets, ok
+}
+
+// underPath returns true if the sample is part of the node at the given
+// path of the tree built with opts.
+func underPath(s Sample, path []Function, opts TreeOptions) bool {
+	functions, ok := opts.stack(s)
+	if !ok || len(functions) < len(path) {
+		return false
+	}
+	lineNumber := !opts.AggregateByFunction
+	for i, f := range path {
+		if functions[i].String(lineNumber) != f.String(lineNumber) {
+			return false
+		}
+	}
+	return true
+}
+
+// pathTo returns the functions from the root of the tree to the
+// given node, nil if the node isn't in the tree.
+func (t *FunctionsTree) pathTo(node *treeNode) []Function {
+	var walk func(n *treeNode, path []Function) []Function
+	walk = func(n *treeNode, path []Function) []Function {
+		if n == node {
+			return path
+		}
+		for _, child := range n.children {
+			if rv := walk(child, append(path, child.function)); rv != nil {
+				return rv
+			}
+		}
+		return nil
+	}
+	if t.root == nil {
+		return nil
+	}
+	return walk(t.root, []Function{})
+}
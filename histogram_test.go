@@ -0,0 +1,113 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSizeBucketBounds(t *testing.T) {
+	tests := []struct {
+		size     int64
+		min, max int64
+	}{
+		{0, 0, 8},
+		{1, 0, 8},
+		{8, 0, 8},
+		{9, 8, 16},
+		{33, 32, 48},
+		{32767, 28672, 32768},
+		{32768, 28672, 32768},
+		// above the size classes, powers of two
+		{32769, 32768, 65536},
+		{65536, 32768, 65536},
+		{65537, 65536, 131072},
+		{1 << 20, 1 << 19, 1 << 20},
+	}
+
+	for _, test := range tests {
+		if min, max := sizeBucketBounds(test.size); min != test.min || max != test.max {
+			t.Errorf("sizeBucketBounds(%d) = %d, %d, want %d, %d", test.size, min, max, test.min, test.max)
+		}
+	}
+}
+
+func TestSizeHistogram(t *testing.T) {
+	// allocSample returns a sample of the stack allocating
+	// the given objects of the given size.
+	allocSample := func(objects, size int64, names ...string) Sample {
+		s := stackSample(objects*size, []Label{{Key: sizeLabel, Num: size, NumUnit: "bytes", Numeric: true}}, names...)
+		s.Objects = objects
+		return s
+	}
+	profile := &Profile{
+		Samples: Samples{
+			allocSample(10, 16, "main", "a", "alloc"),
+			allocSample(1, 40000, "main", "a", "alloc"),
+			allocSample(2, 100000, "main", "b", "alloc"),
+			allocSample(4, 12, "main", "b", "alloc"),
+		},
+	}
+
+	type bucket struct {
+		min, max, value, objects int64
+	}
+	histogram := func(path []Function, opts TreeOptions) []bucket {
+		t.Helper()
+		buckets, ok := profile.SizeHistogram(path, opts)
+		if !ok {
+			t.Fatalf("SizeHistogram() ok = false, want true")
+		}
+		var rv []bucket
+		for _, b := range buckets {
+			rv = append(rv, bucket{b.min, b.max, b.value, b.objects})
+		}
+		return rv
+	}
+
+	opts := TreeOptions{AggregateByFunction: true}
+	all := []bucket{{8, 16, 208, 14}, {32768, 65536, 40000, 1}, {65536, 131072, 200000, 2}}
+	if got := histogram(nil, opts); !reflect.DeepEqual(got, all) {
+		t.Errorf("SizeHistogram() = %v, want %v", got, all)
+	}
+
+	// the samples under main -> a
+	tree := profile.BuildTree("test", opts)
+	var a *treeNode
+	for _, child := range tree.root.children[0].children {
+		if child.function.Name == "a" {
+			a = child
+		}
+	}
+	path := tree.pathTo(a)
+	if got := stackNames(path); got != "main;a" {
+		t.Fatalf("pathTo() = %q, want %q", got, "main;a")
+	}
+	want := []bucket{{8, 16, 160, 10}, {32768, 65536, 40000, 1}}
+	if got := histogram(path, opts); !reflect.DeepEqual(got, want) {
+		t.Errorf("SizeHistogram(main;a) = %v, want %v", got, want)
+	}
+
+	// the samples under alloc -> b in the inverted tree
+	opts.Inverted = true
+	tree = profile.BuildTree("test", opts)
+	b := tree.root.children[0].children[0]
+	path = tree.pathTo(b)
+	if got := stackNames(path); got != "alloc;b" {
+		t.Fatalf("pathTo() = %q, want %q", got, "alloc;b")
+	}
+	want = []bucket{{8, 16, 48, 4}, {65536, 131072, 200000, 2}}
+	if got := histogram(path, opts); !reflect.DeepEqual(got, want) {
+		t.Errorf("SizeHistogram(alloc;b) = %v, want %v", got, want)
+	}
+
+	if path := tree.pathTo(&treeNode{}); path != nil {
+		t.Errorf("pathTo() = %q for a node out of the tree, want nil", stackNames(path))
+	}
+}
+
+func TestSizeHistogramWithoutSizes(t *testing.T) {
+	profile := &Profile{Samples: Samples{stackSample(1, nil, "main")}}
+	if _, ok := profile.SizeHistogram(nil, TreeOptions{}); ok {
+		t.Errorf("SizeHistogram() ok = true, want false")
+	}
+}
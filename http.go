@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// readProtoURL fetches a profile from a net/http/pprof endpoint,
+// e.g. http://localhost:6060/debug/pprof/profile?seconds=30
+func readProtoURL(rawURL string, timeout time.Duration, headers []string, basicAuth string) (*pprof.Profile, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("readProtoURL: url.Parse: %v", err)
+	}
+
+	// the endpoint blocks for the whole capture duration
+	// before answering, don't let the timeout cut it.
+	// ----------------------
+
+	if seconds, err := strconv.Atoi(u.Query().Get("seconds")); err == nil && seconds > 0 {
+		timeout += time.Duration(seconds) * time.Second
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("readProtoURL: http.NewRequest: %v", err)
+	}
+
+	for _, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		req.Header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+
+	if basicAuth != "" {
+		parts := strings.SplitN(basicAuth, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("readProtoURL: invalid basic auth credentials, expected user:password")
+		}
+		req.SetBasicAuth(parts[0], parts[1])
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("readProtoURL: client.Do: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// net/http/pprof explains its errors in the body
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("readProtoURL: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	profile, err := readProto(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("readProtoURL: %v", err)
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// profileServer serves the fixture profile, after waiting for the given
+// delay, to the requests with the expected header and credentials.
+func profileServer(t *testing.T, delay time.Duration) *httptest.Server {
+	data, err := os.ReadFile("testdata/cpu_labels.pb.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/debug/pprof/profile" {
+			http.Error(w, "Unknown profile", http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "missing token", http.StatusForbidden)
+			return
+		}
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "pa:ss" {
+			http.Error(w, "invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		time.Sleep(delay)
+		w.Write(data)
+	}))
+}
+
+func TestReadProtoURL(t *testing.T) {
+	server := profileServer(t, 0)
+	defer server.Close()
+
+	p, err := readProtoURL(server.URL+"/debug/pprof/profile", time.Second, []string{"X-Token: secret"}, "user:pa:ss")
+	if err != nil {
+		t.Fatalf("readProtoURL: %v", err)
+	}
+	if len(p.Sample) == 0 {
+		t.Errorf("readProtoURL: the profile doesn't have any sample")
+	}
+}
+
+func TestReadProtoURLErrors(t *testing.T) {
+	server := profileServer(t, 0)
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		headers   []string
+		basicAuth string
+		want      string
+	}{
+		{"missing header", "/debug/pprof/profile", nil, "user:pa:ss", "403 Forbidden: missing token"},
+		{"wrong credentials", "/debug/pprof/profile", []string{"X-Token: secret"}, "user:wrong", "401 Unauthorized: invalid credentials"},
+		{"invalid credentials", "/debug/pprof/profile", []string{"X-Token: secret"}, "user", "invalid basic auth credentials"},
+		{"unknown profile", "/debug/pprof/unknown", []string{"X-Token: secret"}, "user:pa:ss", "404 Not Found: Unknown profile"},
+	}
+
+	for _, tt := range tests {
+		_, err := readProtoURL(server.URL+tt.path, time.Second, tt.headers, tt.basicAuth)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: readProtoURL() error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestReadProtoURLSecondsTimeout(t *testing.T) {
+	server := profileServer(t, 300*time.Millisecond)
+	defer server.Close()
+
+	url := server.URL + "/debug/pprof/profile"
+	headers := []string{"X-Token: secret"}
+
+	// the capture takes longer than the timeout
+	if _, err := readProtoURL(url, 50*time.Millisecond, headers, "user:pa:ss"); err == nil {
+		t.Errorf("readProtoURL: expected a timeout error")
+	}
+
+	// the seconds of the capture are added to the timeout
+	if _, err := readProtoURL(url+"?seconds=1", 50*time.Millisecond, headers, "user:pa:ss"); err != nil {
+		t.Errorf("readProtoURL: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tagFilter selects the samples by their labels:
+//
+//	key=value  a string label equal to value (or a numeric label equal to it)
+//	key~regex  a label which value matches the regular expression
+//	key=min:max  a numeric label in the range, both bounds included and optional
+//	key        any sample having the label
+type tagFilter struct {
+	expr string
+
+	key      string
+	value    string
+	re       *regexp.Regexp
+	isRange  bool
+	min, max *int64
+}
+
+// tagRange is a numeric range of a tag filter, e.g. "1024:4096" or ":512".
+var tagRange = regexp.MustCompile(`^(-?\d+)?:(-?\d+)?$`)
+
+func parseTagFilter(expr string) (tagFilter, error) {
+	f := tagFilter{expr: expr}
+
+	idx := strings.IndexAny(expr, "=~")
+	if idx < 0 {
+		f.key = strings.TrimSpace(expr)
+		if f.key == "" {
+			return f, fmt.Errorf("parseTagFilter: empty filter")
+		}
+		return f, nil
+	}
+
+	f.key = strings.TrimSpace(expr[:idx])
+	f.value = expr[idx+1:]
+	if f.key == "" {
+		return f, fmt.Errorf("parseTagFilter: %q: missing label key", expr)
+	}
+
+	if expr[idx] == '~' {
+		re, err := regexp.Compile(f.value)
+		if err != nil {
+			return f, fmt.Errorf("parseTagFilter: %q: %v", expr, err)
+		}
+		f.re = re
+		return f, nil
+	}
+
+	if matches := tagRange.FindStringSubmatch(f.value); matches != nil {
+		f.isRange = true
+		if matches[1] != "" {
+			min, _ := strconv.ParseInt(matches[1], 10, 64)
+			f.min = &min
+		}
+		if matches[2] != "" {
+			max, _ := strconv.ParseInt(matches[2], 10, 64)
+			f.max = &max
+		}
+	}
+
+	return f, nil
+}
+
+func (f tagFilter) String() string {
+	return f.expr
+}
+
+// matches returns true if one of the labels matches the filter.
+func (f tagFilter) matches(labels []Label) bool {
+	for _, l := range labels {
+		if l.Key != f.key {
+			continue
+		}
+
+		switch {
+		case f.re != nil:
+			if f.re.MatchString(l.rawValue()) {
+				return true
+			}
+		case f.isRange:
+			if l.IsNumeric() && (f.min == nil || l.Num >= *f.min) && (f.max == nil || l.Num <= *f.max) {
+				return true
+			}
+		case f.value != "":
+			if l.rawValue() == f.value {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// matchesTags returns true if the labels match all the focus filters
+// and none of the ignore filters.
+func (opts TreeOptions) matchesTags(labels []Label) bool {
+	for _, f := range opts.TagFocus {
+		if !f.matches(labels) {
+			return false
+		}
+	}
+	for _, f := range opts.TagIgnore {
+		if f.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// groupFunction returns the synthetic function grouping the samples
+// in the tree by the value of their label with the given key.
+func groupFunction(key string, labels []Label) Function {
+	var values []string
+	for _, l := range labels {
+		if l.Key == key {
+			values = append(values, l.Value())
+		}
+	}
+	if len(values) == 0 {
+		return Function{Name: fmt.Sprintf("%s (unlabeled)", key)}
+	}
+	return Function{Name: fmt.Sprintf("%s=%s", key, strings.Join(values, ","))}
+}
+
+// rawValue returns the value of the label as it is matched by the filters.
+func (l Label) rawValue() string {
+	if l.IsNumeric() {
+		return strconv.FormatInt(l.Num, 10)
+	}
+	return l.Str
+}
+
+// Value returns the value of the label formatted for display.
+func (l Label) Value() string {
+	if l.IsNumeric() && l.NumUnit != "" {
+		return formatValue(l.Num, l.NumUnit)
+	}
+	return l.rawValue()
+}
+
+// labelKey is a label key of the profile, with the cost of
+// the samples having each of its values.
+type labelKey struct {
+	key     string
+	value   int64
+	percent float64
+	values  []labelValue
+}
+
+type labelValue struct {
+	label   Label
+	value   int64
+	percent float64
+	samples int
+}
+
+// Labels returns the label keys of the samples part of the tree built
+// with the given options, and the cost of each of their values. The keys
+// are sorted by name, the values by decreasing cost. In a differential
+// view, the costs are the deltas with the base profile.
+func (p *Profile) Labels(opts TreeOptions) []labelKey {
+	keys := make(map[string]*labelKey)
+	values := make(map[string]map[string]*labelValue)
+
+	samples := p.Samples
+	total := float64(p.TotalSampling)
+	if p.Base != nil {
+		samples = p.diffSamples()
+		total = p.diffTotal()
+	}
+
+	for _, s := range samples {
+		if _, ok := opts.stack(s); !ok || s.Value == 0 {
+			continue
+		}
+
+		// a key is counted once per sample even if it has several values
+		seen := make(map[string]bool)
+		for _, l := range s.Labels {
+			k, ok := keys[l.Key]
+			if !ok {
+				k = &labelKey{key: l.Key}
+				keys[l.Key] = k
+				values[l.Key] = make(map[string]*labelValue)
+			}
+			if !seen[l.Key] {
+				k.value += s.Value
+				seen[l.Key] = true
+			}
+
+			v, ok := values[l.Key][l.rawValue()]
+			if !ok {
+				v = &labelValue{label: l}
+				values[l.Key][l.rawValue()] = v
+			}
+			v.value += s.Value
+			v.samples++
+		}
+	}
+
+	rv := make([]labelKey, 0, len(keys))
+	for name, k := range keys {
+		k.percent = float64(k.value) / total * 100.0
+		for _, v := range values[name] {
+			v.percent = float64(v.value) / total * 100.0
+			k.values = append(k.values, *v)
+		}
+		sort.Slice(k.values, func(i, j int) bool {
+			if abs(k.values[i].value) != abs(k.values[j].value) {
+				return abs(k.values[i].value) > abs(k.values[j].value)
+			}
+			return k.values[i].label.rawValue() < k.values[j].label.rawValue()
+		})
+		rv = append(rv, *k)
+	}
+
+	sort.Slice(rv, func(i, j int) bool {
+		return rv[i].key < rv[j].key
+	})
+
+	return rv
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTagFilter(t *testing.T) {
+	labels := func(l ...Label) []Label { return l }
+	route := Label{Key: "route", Str: "/api/users"}
+	admin := Label{Key: "route", Str: "/admin"}
+	size := Label{Key: "bytes", Num: 2048, NumUnit: "bytes", Numeric: true}
+	negative := Label{Key: "delta", Num: -5, Numeric: true}
+
+	tests := []struct {
+		expr    string
+		err     string
+		match   [][]Label
+		noMatch [][]Label
+	}{
+		{
+			expr:    "route",
+			match:   [][]Label{labels(route), labels(size, admin)},
+			noMatch: [][]Label{nil, labels(size)},
+		},
+		{
+			expr:    "route=/admin",
+			match:   [][]Label{labels(admin), labels(route, admin)},
+			noMatch: [][]Label{labels(route), labels(Label{Key: "other", Str: "/admin"})},
+		},
+		{
+			expr:    " route =/api/users",
+			match:   [][]Label{labels(route)},
+			noMatch: [][]Label{labels(admin)},
+		},
+		{
+			expr:    "route~^/api/",
+			match:   [][]Label{labels(route)},
+			noMatch: [][]Label{labels(admin)},
+		},
+		{
+			expr:    "bytes=1024:4096",
+			match:   [][]Label{labels(size)},
+			noMatch: [][]Label{labels(Label{Key: "bytes", Num: 8192, Numeric: true}), labels(Label{Key: "bytes", Str: "2048"})},
+		},
+		{
+			expr:    "bytes=0:10",
+			match:   [][]Label{labels(Label{Key: "bytes", Numeric: true})},
+			noMatch: [][]Label{labels(Label{Key: "bytes", Str: ""})},
+		},
+		{
+			expr:    "bytes=:1024",
+			match:   [][]Label{labels(Label{Key: "bytes", Num: 1024, Numeric: true})},
+			noMatch: [][]Label{labels(size)},
+		},
+		{
+			expr:    "bytes=2048:",
+			match:   [][]Label{labels(size)},
+			noMatch: [][]Label{labels(Label{Key: "bytes", Num: 2047, Numeric: true})},
+		},
+		{
+			expr:    "delta=-10:-1",
+			match:   [][]Label{labels(negative)},
+			noMatch: [][]Label{labels(Label{Key: "delta", Num: 1, Numeric: true})},
+		},
+		{
+			expr:    "bytes=2048",
+			match:   [][]Label{labels(size)},
+			noMatch: [][]Label{labels(Label{Key: "bytes", Num: 20480, Numeric: true})},
+		},
+		{expr: "", err: "empty filter"},
+		{expr: "  ", err: "empty filter"},
+		{expr: "=value", err: "missing label key"},
+		{expr: "route~(", err: "missing closing )"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			f, err := parseTagFilter(test.expr)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("parseTagFilter() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTagFilter() error = %v", err)
+			}
+			if f.String() != test.expr {
+				t.Errorf("String() = %q, want %q", f.String(), test.expr)
+			}
+
+			for _, l := range test.match {
+				if !f.matches(l) {
+					t.Errorf("matches(%v) = false, want true", l)
+				}
+			}
+			for _, l := range test.noMatch {
+				if f.matches(l) {
+					t.Errorf("matches(%v) = true, want false", l)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffTagFocus(t *testing.T) {
+	a := []Label{{Key: "tenant", Str: "a"}}
+	b := []Label{{Key: "tenant", Str: "b"}}
+	base := &Profile{
+		Samples: Samples{
+			stackSample(10, a, "main", "work"),
+			stackSample(10, b, "main", "work"),
+		},
+		TotalSampling: 20,
+	}
+	profile := &Profile{
+		Samples: Samples{
+			stackSample(15, a, "main", "work"),
+			stackSample(10, b, "main", "work"),
+		},
+		TotalSampling: 25,
+		Base:          base,
+	}
+
+	f, err := parseTagFilter("tenant=a")
+	if err != nil {
+		t.Fatalf("parseTagFilter() error = %v", err)
+	}
+	opts := TreeOptions{AggregateByFunction: true, TagFocus: []tagFilter{f}}
+
+	// only the samples of the tenant a, in both profiles
+	top := profile.Top(opts, TopSortName)
+	if len(top) != 2 {
+		t.Fatalf("Top() = %d entries, want 2", len(top))
+	}
+	for _, e := range top {
+		if e.cum != 5 {
+			t.Errorf("Top() %s cum = %d, want 5", e.function.Name, e.cum)
+		}
+	}
+}
+
+func TestDiffLabels(t *testing.T) {
+	a := []Label{{Key: "tenant", Str: "a"}}
+	b := []Label{{Key: "tenant", Str: "b"}}
+	c := []Label{{Key: "tenant", Str: "c"}}
+	base := &Profile{
+		Samples: Samples{
+			stackSample(10, a, "main", "work"),
+			stackSample(10, b, "main", "work"),
+		},
+		TotalSampling: 20,
+	}
+	profile := &Profile{
+		Samples: Samples{
+			stackSample(15, a, "main", "work"),
+			stackSample(2, b, "main", "work"),
+			stackSample(1, c, "main", "work"),
+		},
+		TotalSampling: 18,
+		Base:          base,
+	}
+
+	keys := profile.Labels(TreeOptions{})
+	if len(keys) != 1 || keys[0].key != "tenant" {
+		t.Fatalf("Labels() = %+v, want the tenant key", keys)
+	}
+	if keys[0].value != -2 || keys[0].percent != -10 {
+		t.Errorf("Labels() tenant = %d (%.2f%%), want -2 (-10.00%%)", keys[0].value, keys[0].percent)
+	}
+
+	type value struct {
+		name  string
+		value int64
+	}
+	var got []value
+	for _, v := range keys[0].values {
+		got = append(got, value{v.label.rawValue(), v.value})
+	}
+	want := []value{{"b", -8}, {"a", 5}, {"c", 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Labels() tenant values = %v, want %v", got, want)
+	}
+}
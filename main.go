@@ -1,7 +1,6 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"os"
 	"runtime"
@@ -11,26 +10,68 @@ import (
 
 func main() {
 	runtime.LockOSThread()
-	if config.File == "" {
-		flag.PrintDefaults()
+
+	command, fs := parseFlags(os.Args[1:])
+	if config.File == "" && config.URL == "" {
+		fs.Usage()
 		os.Exit(-1)
 	}
 
 	var err error
 
-	// read the pprof file
+	// read the pprof file or fetch it
 	// ----------------------
 
 	var pprofProfile *pprof.Profile
 
-	if pprofProfile, err = readProtoFile(config.File); err != nil {
+	if config.URL != "" {
+		pprofProfile, err = readProtoURL(config.URL, config.Timeout, config.Headers, config.BasicAuth)
+	} else {
+		pprofProfile, err = readProtoFiles(config.File)
+	}
+
+	if err != nil {
 		fmt.Println("err:", err)
 		os.Exit(-1)
 	}
 
-	// start the gui
+	// read the baseline profile in differential view
 	// ----------------------
 
-	gui := NewGUI(pprofProfile)
-	gui.OpenWindow()
+	var basePprofProfile *pprof.Profile
+
+	if config.Base != "" {
+		if basePprofProfile, err = readProtoFiles(config.Base); err != nil {
+			fmt.Println("err: base profile:", err)
+			os.Exit(-1)
+		}
+	}
+
+	// run the command
+	// ----------------------
+
+	switch command {
+	case "export":
+		if err := export(pprofProfile, basePprofProfile); err != nil {
+			fmt.Fprintln(os.Stderr, "err:", err)
+			os.Exit(-1)
+		}
+	case "report":
+		if err := report(pprofProfile, basePprofProfile); err != nil {
+			fmt.Fprintln(os.Stderr, "err:", err)
+			os.Exit(-1)
+		}
+	case "check":
+		passed, err := check(pprofProfile, basePprofProfile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "err:", err)
+			os.Exit(-1)
+		}
+		if !passed {
+			os.Exit(1)
+		}
+	default:
+		gui := NewGUI(pprofProfile, basePprofProfile)
+		gui.OpenWindow()
+	}
 }
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// readProtoFiles reads the profiles matching the given comma-separated
+// list of files or glob patterns and merges them in a single profile.
+func readProtoFiles(spec string) (*pprof.Profile, error) {
+	filenames, err := expandFiles(spec)
+	if err != nil {
+		return nil, fmt.Errorf("readProtoFiles: %v", err)
+	}
+
+	var profiles []*pprof.Profile
+	for _, filename := range filenames {
+		profile, err := readProtoFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("readProtoFiles: %s: %v", filename, err)
+		}
+		profiles = append(profiles, profile)
+	}
+
+	if len(profiles) == 1 {
+		return profiles[0], nil
+	}
+
+	profile, err := mergeProfiles(profiles)
+	if err != nil {
+		return nil, fmt.Errorf("readProtoFiles: %v", err)
+	}
+	return profile, nil
+}
+
+// expandFiles returns the files of the comma-separated list of files
+// or glob patterns.
+func expandFiles(spec string) ([]string, error) {
+	var rv []string
+	for _, pattern := range strings.Split(spec, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		// not a pattern, let the read fail if the file doesn't exist
+		if pattern == "-" || !strings.ContainsAny(pattern, "*?[") {
+			rv = append(rv, pattern)
+			continue
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("expandFiles: %q: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("expandFiles: no file matching %q", pattern)
+		}
+		rv = append(rv, matches...)
+	}
+
+	if len(rv) == 0 {
+		return nil, fmt.Errorf("expandFiles: no file to read")
+	}
+
+	return rv, nil
+}
+
+// mergeProfiles merges the samples of the given profiles in a single profile.
+// All the profiles must have the same sample types.
+func mergeProfiles(profiles []*pprof.Profile) (*pprof.Profile, error) {
+	// the indexes are used without checks when copying the
+	// profiles, a malformed profile would panic.
+	for i, p := range profiles {
+		if err := checkStringIndexes(p); err != nil {
+			return nil, fmt.Errorf("mergeProfiles: profile #%d: %v", i+1, err)
+		}
+	}
+
+	first := profiles[0]
+	sampleTypes := sampleTypesNames(first)
+
+	for i, p := range profiles[1:] {
+		if names := sampleTypesNames(p); names != sampleTypes {
+			return nil, fmt.Errorf("mergeProfiles: profile #%d has the sample types [%s], incompatible with [%s]", i+2, names, sampleTypes)
+		}
+	}
+
+	// the header of the merged profile is the one
+	// of the first profile.
+	// ----------------------
+
+	b := newProfileBuilder()
+	rv := b.profile
+
+	for _, st := range first.SampleType {
+		rv.SampleType = append(rv.SampleType, b.valueType(first.StringTable[st.Type], first.StringTable[st.Unit]))
+	}
+	// the period type is optional, use the first one available
+	for _, p := range profiles {
+		if p.PeriodType != nil {
+			rv.PeriodType = b.valueType(p.StringTable[p.PeriodType.Type], p.StringTable[p.PeriodType.Unit])
+			break
+		}
+	}
+	rv.Period = first.Period
+	rv.DefaultSampleType = b.str(first.StringTable[first.DefaultSampleType])
+	rv.DropFrames = b.str(first.StringTable[first.DropFrames])
+	rv.KeepFrames = b.str(first.StringTable[first.KeepFrames])
+	rv.TimeNanos = first.TimeNanos
+
+	// copy the samples of every profile, remapping all
+	// the IDs and strings indexes.
+	// ----------------------
+
+	for _, p := range profiles {
+		str := func(idx int64) string { return p.StringTable[idx] }
+
+		if p.TimeNanos != 0 && p.TimeNanos < rv.TimeNanos {
+			rv.TimeNanos = p.TimeNanos
+		}
+		rv.DurationNanos += p.DurationNanos
+		for _, c := range p.Comment {
+			rv.Comment = append(rv.Comment, b.str(str(c)))
+		}
+
+		mappings := make(map[uint64]uint64)
+		for _, m := range p.Mapping {
+			mappings[m.Id] = b.mapping(m, str(m.Filename), str(m.BuildId))
+		}
+
+		functions := make(map[uint64]uint64)
+		for _, f := range p.Function {
+			functions[f.Id] = b.function(str(f.Name), str(f.SystemName), str(f.Filename), f.StartLine)
+		}
+
+		locations := make(map[uint64]uint64)
+		for _, l := range p.Location {
+			lines := make([]*pprof.Line, len(l.Line))
+			for i, line := range l.Line {
+				lines[i] = &pprof.Line{FunctionId: functions[line.FunctionId], Line: line.Line}
+			}
+			locations[l.Id] = b.location(mappings[l.MappingId], l.Address, lines)
+		}
+
+		for _, s := range p.Sample {
+			sample := &pprof.Sample{
+				LocationId: make([]uint64, len(s.LocationId)),
+				Value:      s.Value,
+			}
+			for i, id := range s.LocationId {
+				location, ok := locations[id]
+				if !ok {
+					return nil, fmt.Errorf("mergeProfiles: unknown location %d", id)
+				}
+				sample.LocationId[i] = location
+			}
+			for _, l := range s.Label {
+				sample.Label = append(sample.Label, &pprof.Label{
+					Key:     b.str(str(l.Key)),
+					Str:     b.str(str(l.Str)),
+					Num:     l.Num,
+					NumUnit: b.str(str(l.NumUnit)),
+				})
+			}
+			rv.Sample = append(rv.Sample, sample)
+		}
+	}
+
+	return rv, nil
+}
+
+// checkStringIndexes returns an error if one of the strings
+// of the profile isn't in its strings table.
+func checkStringIndexes(p *pprof.Profile) error {
+	check := func(what string, indexes ...int64) error {
+		for _, idx := range indexes {
+			if idx < 0 || idx >= int64(len(p.StringTable)) {
+				return fmt.Errorf("checkStringIndexes: %s: string %d out of the strings table", what, idx)
+			}
+		}
+		return nil
+	}
+
+	var errs []error
+	for _, st := range p.SampleType {
+		errs = append(errs, check("sample type", st.Type, st.Unit))
+	}
+	if p.PeriodType != nil {
+		errs = append(errs, check("period type", p.PeriodType.Type, p.PeriodType.Unit))
+	}
+	errs = append(errs,
+		check("default sample type", p.DefaultSampleType),
+		check("drop frames", p.DropFrames),
+		check("keep frames", p.KeepFrames),
+		check("comments", p.Comment...),
+	)
+	for _, m := range p.Mapping {
+		errs = append(errs, check("mapping", m.Filename, m.BuildId))
+	}
+	for _, f := range p.Function {
+		errs = append(errs, check("function", f.Name, f.SystemName, f.Filename))
+	}
+	for _, s := range p.Sample {
+		for _, l := range s.Label {
+			errs = append(errs, check("label", l.Key, l.Str, l.NumUnit))
+		}
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sampleTypesNames returns the sample types of the profile, e.g.
+// "alloc_objects/count alloc_space/bytes"
+func sampleTypesNames(p *pprof.Profile) string {
+	var names []string
+	for _, st := range p.SampleType {
+		names = append(names, p.StringTable[st.Type]+"/"+p.StringTable[st.Unit])
+	}
+	return strings.Join(names, " ")
+}
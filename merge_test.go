@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// mergeTestProfile returns a cpu profile with the given stacks, the root
+// first. The strings, functions and locations are created in the order of
+// the given preamble frames first, to have different IDs in each profile.
+func mergeTestProfile(preamble []string, stacks map[string]int64, label string) *pprof.Profile {
+	b := newProfileBuilder()
+	for _, name := range preamble {
+		b.frame(name, name+".go", 1)
+	}
+	b.profile.SampleType = []*pprof.ValueType{b.valueType("cpu", "nanoseconds")}
+	b.profile.PeriodType = b.valueType("cpu", "nanoseconds")
+
+	for stack, value := range stacks {
+		frames := strings.Split(stack, ";")
+		sample := &pprof.Sample{
+			Value: []int64{value},
+			Label: []*pprof.Label{{Key: b.str("replica"), Str: b.str(label)}},
+		}
+		for i := len(frames) - 1; i >= 0; i-- {
+			sample.LocationId = append(sample.LocationId, b.frame(frames[i], frames[i]+".go", 1))
+		}
+		b.profile.Sample = append(b.profile.Sample, sample)
+	}
+	return b.profile
+}
+
+func TestMergeProfiles(t *testing.T) {
+	first := mergeTestProfile(nil, map[string]int64{"main;a": 10, "main;b": 5}, "one")
+	second := mergeTestProfile([]string{"z", "b", "y"}, map[string]int64{"main;b": 7, "main;c;a": 3}, "two")
+
+	merged, err := mergeProfiles([]*pprof.Profile{first, second})
+	if err != nil {
+		t.Fatalf("mergeProfiles() error = %v", err)
+	}
+	equalStacks(t, foldedStacks(t, merged, ModeDefault), map[string]int64{
+		"main;a":   10,
+		"main;b":   12,
+		"main;c;a": 3,
+	})
+
+	// the functions keep their files and the samples their labels
+	profile, err := NewProfile(merged, ModeDefault, nil)
+	if err != nil {
+		t.Fatalf("NewProfile() error = %v", err)
+	}
+	replicas := make(map[string]int64)
+	for _, s := range profile.Samples {
+		for _, f := range s.Functions {
+			if f.File != f.Name+".go" {
+				t.Errorf("function %s file = %q, want %q", f.Name, f.File, f.Name+".go")
+			}
+		}
+		for _, l := range s.Labels {
+			replicas[l.Key+"="+l.Value()] += s.Value
+		}
+	}
+	if replicas["replica=one"] != 15 || replicas["replica=two"] != 10 || len(replicas) != 2 {
+		t.Errorf("labels = %v, want replica=one 15 and replica=two 10", replicas)
+	}
+}
+
+func TestMergeProfilesErrors(t *testing.T) {
+	valid := func() *pprof.Profile { return mergeTestProfile(nil, map[string]int64{"main": 1}, "one") }
+
+	defaultSampleType := valid()
+	defaultSampleType.DefaultSampleType = 99
+
+	functionName := valid()
+	functionName.Function[0].Name = -1
+
+	label := valid()
+	label.Sample[0].Label[0].Str = int64(len(label.StringTable))
+
+	location := valid()
+	location.Sample[0].LocationId = []uint64{42}
+
+	heap := valid()
+	heap.SampleType[0] = &pprof.ValueType{Type: 1, Unit: 1}
+
+	tests := []struct {
+		name    string
+		profile *pprof.Profile
+		err     string
+	}{
+		{"default sample type", defaultSampleType, "profile #2: checkStringIndexes: default sample type: string 99"},
+		{"function name", functionName, "profile #2: checkStringIndexes: function: string -1"},
+		{"label", label, "profile #2: checkStringIndexes: label"},
+		{"location", location, "unknown location 42"},
+		{"sample types", heap, "profile #2 has the sample types"},
+	}
+
+	for _, test := range tests {
+		_, err := mergeProfiles([]*pprof.Profile{valid(), test.profile})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: mergeProfiles() error = %v, want %q", test.name, err, test.err)
+		}
+	}
+}
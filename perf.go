@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/remeh/diago/pprof"
+)
+
+var (
+	// perfHeader is the first line of a sample of the `perf script` output:
+	// comm, pid[/tid], [cpu], timestamp, [period] and event, e.g.
+	//   myservice 1234/1240 [003] 1234.567890:   250000 cpu-clock:pppH:
+	perfHeader = regexp.MustCompile(`^(\S.*?)\s+(\d+)(?:/(\d+))?\s+(?:\[\d+\]\s+)?(?:\d+\.\d+:\s+)?(?:(\d+)\s+)?(\S+):(?:\s|$)`)
+	// perfFrame is a frame of the stack of a sample: address, symbol
+	// with its offset and DSO, e.g.
+	//   	4b2a31 main.compute+0x31 (/usr/bin/myservice)
+	perfFrame = regexp.MustCompile(`^\s+([0-9a-fA-F]+)\s*(.*?)(?:\s+\((.*)\))?$`)
+	// perfModifiers are the modifiers of an event, e.g. the ":u" of "cycles:u".
+	perfModifiers = regexp.MustCompile(`:[ukhIGHpPSDWe]+$`)
+	// perfSymbolOffset is the offset of the address in a symbol.
+	perfSymbolOffset = regexp.MustCompile(`\+0x[0-9a-fA-F]+$`)
+)
+
+// perfSample is a sample read from the `perf script` output,
+// its frames are the leaf first.
+type perfSample struct {
+	comm   string
+	pid    int64
+	tid    int64
+	event  string
+	period int64
+	frames []perfFrameInfo
+	// ip is the frame written on the header line when the sample
+	// has no callchain, e.g. with `perf script -F +ip,+sym`.
+	ip *perfFrameInfo
+}
+
+type perfFrameInfo struct {
+	address uint64
+	symbol  string
+	dso     string
+}
+
+// looksLikePerfScript returns true if the first lines of the data
+// are in the `perf script` output format.
+func looksLikePerfScript(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+
+	lines := 0
+	headers := 0
+	for scanner.Scan() && lines < 10 {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case perfHeader.MatchString(line):
+			headers++
+		case headers > 0 && perfFrame.MatchString(line):
+		default:
+			return false
+		}
+		lines++
+	}
+	return headers > 0
+}
+
+// readPerfScript converts the output of `perf script` to a pprof profile.
+// The profile has a "samples" sample type and a sample type per event
+// containing the sum of the periods. The DSOs are kept as mappings,
+// and the comm, pid and tid as labels of the samples.
+func readPerfScript(data []byte) (*pprof.Profile, error) {
+	var samples []*perfSample
+	var events []string
+	eventIndexes := make(map[string]int)
+
+	// read the samples
+	// ----------------------
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16*1024*1024)
+
+	var current *perfSample
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			current = nil
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// the frames are indented, without callchain the
+		// samples aren't separated by empty lines.
+		if current != nil && (line[0] == ' ' || line[0] == '\t') {
+			frame, err := parsePerfFrame(line)
+			if err != nil {
+				return nil, fmt.Errorf("readPerfScript: line %d: %v", lineNumber, err)
+			}
+			current.frames = append(current.frames, *frame)
+			continue
+		}
+
+		matches := perfHeader.FindStringSubmatch(line)
+		if matches == nil {
+			return nil, fmt.Errorf("readPerfScript: line %d: invalid sample header: %q", lineNumber, line)
+		}
+
+		current = &perfSample{
+			comm:   matches[1],
+			event:  perfModifiers.ReplaceAllString(matches[5], ""),
+			period: 1,
+		}
+		current.pid, _ = strconv.ParseInt(matches[2], 10, 64)
+		current.tid = current.pid
+		if matches[3] != "" {
+			current.tid, _ = strconv.ParseInt(matches[3], 10, 64)
+		}
+		if matches[4] != "" {
+			current.period, _ = strconv.ParseInt(matches[4], 10, 64)
+		}
+		if rest := line[len(matches[0]):]; strings.TrimSpace(rest) != "" {
+			ip, err := parsePerfFrame(" " + rest)
+			if err != nil {
+				return nil, fmt.Errorf("readPerfScript: line %d: %v", lineNumber, err)
+			}
+			current.ip = ip
+		}
+		if _, ok := eventIndexes[current.event]; !ok {
+			eventIndexes[current.event] = len(events)
+			events = append(events, current.event)
+		}
+		samples = append(samples, current)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("readPerfScript: %v", err)
+	}
+
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("readPerfScript: no samples")
+	}
+
+	// build the profile
+	// ----------------------
+
+	b := newProfileBuilder()
+	b.profile.SampleType = []*pprof.ValueType{b.valueType("samples", "count")}
+	for _, event := range events {
+		b.profile.SampleType = append(b.profile.SampleType, b.valueType(event, perfEventUnit(event)))
+	}
+	b.profile.PeriodType = b.valueType(events[0], perfEventUnit(events[0]))
+	b.profile.DefaultSampleType = b.str(events[0])
+
+	for _, s := range samples {
+		sample := &pprof.Sample{
+			Value: make([]int64, len(b.profile.SampleType)),
+			Label: []*pprof.Label{
+				{Key: b.str("comm"), Str: b.str(s.comm)},
+				{Key: b.str("pid"), Num: s.pid},
+				{Key: b.str("tid"), Num: s.tid},
+			},
+		}
+		sample.Value[0] = 1
+		sample.Value[eventIndexes[s.event]+1] = s.period
+
+		// without callchain, the stack is the frame of the sample itself
+		frames := s.frames
+		if len(frames) == 0 && s.ip != nil {
+			frames = []perfFrameInfo{*s.ip}
+		}
+		for _, f := range frames {
+			sample.LocationId = append(sample.LocationId, b.perfLocation(f))
+		}
+		b.profile.Sample = append(b.profile.Sample, sample)
+	}
+
+	return b.profile, nil
+}
+
+// parsePerfFrame parses a frame of the stack of a sample.
+func parsePerfFrame(line string) (*perfFrameInfo, error) {
+	matches := perfFrame.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, fmt.Errorf("parsePerfFrame: invalid frame: %q", line)
+	}
+	address, err := strconv.ParseUint(matches[1], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsePerfFrame: %v", err)
+	}
+	return &perfFrameInfo{
+		address: address,
+		symbol:  matches[2],
+		dso:     matches[3],
+	}, nil
+}
+
+// perfLocation returns the ID of the location of a frame. The unknown
+// symbols are left unsymbolized so their address and DSO are displayed.
+func (b *profileBuilder) perfLocation(f perfFrameInfo) uint64 {
+	var mappingID uint64
+	if f.dso != "" && f.dso != "[unknown]" {
+		mappingID = b.mapping(&pprof.Mapping{HasFunctions: true}, f.dso, "")
+	}
+
+	symbol := perfSymbolOffset.ReplaceAllString(f.symbol, "")
+	if symbol == "" || symbol == "[unknown]" {
+		return b.location(mappingID, f.address, nil)
+	}
+
+	functionID := b.function(symbol, symbol, f.dso, 0)
+	return b.location(mappingID, f.address, []*pprof.Line{{FunctionId: functionID}})
+}
+
+// perfEventUnit returns the unit of the period of a perf event: the
+// software clock events count nanoseconds, others count events.
+func perfEventUnit(event string) string {
+	switch event {
+	case "cpu-clock", "task-clock":
+		return "nanoseconds"
+	}
+	return "count"
+}
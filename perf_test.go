@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const perfScript = `# ========
+# captured on: Mon Oct  5 10:00:00 2026
+# ========
+myservice 1234/1240 [003] 1234.567890:     250000 cpu-clock:pppH: 
+	    4b2a31 main.compute+0x31 (/usr/bin/myservice)
+	    4b2b00 main.main+0x20 (/usr/bin/myservice)
+	    43d1c5 runtime.main+0x205 (/usr/bin/myservice)
+
+myservice 1234/1241 [001] 1234.567900:     250000 cpu-clock:pppH: 
+	    4b2a31 main.compute+0x31 (/usr/bin/myservice)
+	    4b2b00 main.main+0x20 (/usr/bin/myservice)
+	    43d1c5 runtime.main+0x205 (/usr/bin/myservice)
+
+my worker 99 1234.568000:          7 cycles:u: 
+	7f12a3b4c5d6 [unknown] ([unknown])
+	    4b2b00 main.main+0x20 (/usr/bin/myservice)
+`
+
+func TestLooksLikePerfScript(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"perf script", perfScript, true},
+		{"without period", "swapper 0 [000] 1.000: cycles: \n\t ffffffff81 native_safe_halt ([kernel.kallsyms])\n", true},
+		{"only comments", "# perf\n# script\n", false},
+		{"folded", "main;foo 12\n", false},
+		{"frame first", "\t4b2a31 main.compute+0x31 (/usr/bin/myservice)\n", false},
+	}
+
+	for _, test := range tests {
+		if got := looksLikePerfScript([]byte(test.data)); got != test.want {
+			t.Errorf("%s: looksLikePerfScript() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestReadPerfScript(t *testing.T) {
+	p, err := readPerfScript([]byte(perfScript))
+	if err != nil {
+		t.Fatalf("readPerfScript() error = %v", err)
+	}
+
+	tests := []struct {
+		mode sampleMode
+		want map[string]int64
+	}{
+		{
+			mode: ModeDefault,
+			want: map[string]int64{"runtime.main;main.main;main.compute": 500000},
+		},
+		{
+			mode: "samples",
+			want: map[string]int64{
+				"runtime.main;main.main;main.compute": 2,
+				"main.main;0x7f12a3b4c5d6":            1,
+			},
+		},
+		{
+			mode: "cycles",
+			want: map[string]int64{"main.main;0x7f12a3b4c5d6": 7},
+		},
+	}
+
+	for _, test := range tests {
+		name := string(test.mode)
+		if name == "" {
+			name = "default"
+		}
+		t.Run(name, func(t *testing.T) {
+			equalStacks(t, foldedStacks(t, p, test.mode), test.want)
+		})
+	}
+
+	profile, err := NewProfile(p, "samples", nil)
+	if err != nil {
+		t.Fatalf("NewProfile() error = %v", err)
+	}
+	var labels []string
+	for _, s := range profile.Samples {
+		for _, l := range s.Labels {
+			labels = append(labels, l.Key+"="+l.Value())
+		}
+	}
+	want := "comm=myservice pid=1234 tid=1240 comm=myservice pid=1234 tid=1241 comm=my worker pid=99 tid=99"
+	if got := strings.Join(labels, " "); got != want {
+		t.Errorf("labels = %q, want %q", got, want)
+	}
+}
+
+func TestReadPerfScriptWithoutCallchain(t *testing.T) {
+	// perf record without -g, the frame of the sample is on its header line
+	data := `myservice 1234 [003] 1234.567890:     250000 cpu-clock:pppH:      4b2a31 main.compute+0x31 (/usr/bin/myservice)
+myservice 1234 [003] 1234.568890:     250000 cpu-clock:pppH:      7f12a3b4c5d6 [unknown] ([unknown])
+myservice 1234 [001] 1234.569890:     250000 cpu-clock:pppH:      4b2a31 main.compute+0x31 (/usr/bin/myservice)
+`
+	if !looksLikePerfScript([]byte(data)) {
+		t.Fatalf("looksLikePerfScript() = false, want true")
+	}
+	p, err := readPerfScript([]byte(data))
+	if err != nil {
+		t.Fatalf("readPerfScript() error = %v", err)
+	}
+	equalStacks(t, foldedStacks(t, p, ModeDefault), map[string]int64{
+		"main.compute":   500000,
+		"0x7f12a3b4c5d6": 250000,
+	})
+}
+
+func TestReadPerfScriptErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"empty", "# nothing\n", "no samples"},
+		{"invalid header", "not a sample header\n", "line 1: invalid sample header"},
+		{"invalid frame", "app 1 1.0: cycles: \n\tnot a frame\n", "line 2: parsePerfFrame: invalid frame"},
+		{"invalid sample frame", "app 1 1.0: cycles: not a frame\n", "line 1: parsePerfFrame: invalid frame"},
+	}
+
+	for _, test := range tests {
+		if _, err := readPerfScript([]byte(test.data)); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: readPerfScript() error = %v, want %q", test.name, err, test.err)
+		}
+	}
+}
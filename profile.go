@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/remeh/diago/pprof"
@@ -13,15 +12,49 @@ type Profile struct {
 	TotalSampling   uint64
 	CaptureDuration time.Duration
 
-	// "cpu" or "heap"
+	// "cpu", "heap" or the period type of the profile
+	// (e.g. "goroutine", "contentions", ...)
 	Type string
 
+	// SampleTypes are all the values available in the profile samples,
+	// SampleType is the one which has been read.
+	SampleTypes []ValueType
+	SampleType  ValueType
+
+	// Base is the baseline profile this profile is compared to,
+	// nil when not displaying a differential view.
+	Base *Profile
+
+	// HasObjectSizes is true when both the bytes and the objects count
+	// are available in the samples, e.g. in heap profiles.
+	HasObjectSizes bool
+
+	// PruneRules are the rules which have pruned the stacks of the
+	// samples: the drop_frames of the profile and the user rules.
+	PruneRules []pruneRule
+
 	functionsMapByLocation ManyFunctionsMap
 	locationsMap           LocationsMap
 	stringsMap             StringsMap
 }
 
-func NewProfile(p *pprof.Profile, mode sampleMode) (*Profile, error) {
+// sampleMode is the name of the sample type to read from a profile.
+type sampleMode string
+
+var (
+	// use this when you don't really know the mode
+	// to use to read the profile.
+	ModeDefault          sampleMode = ""
+	ModeCpu              sampleMode = "cpu"
+	ModeHeapAlloc        sampleMode = "alloc_space"
+	ModeHeapInuse        sampleMode = "inuse_space"
+	ModeHeapAllocObjects sampleMode = "alloc_objects"
+	ModeHeapInuseObjects sampleMode = "inuse_objects"
+)
+
+// NewProfile reads the given sample type of the profile, pruning the
+// stacks of its samples with its drop_frames and the given rules.
+func NewProfile(p *pprof.Profile, mode sampleMode, pruneRules []pruneRule) (*Profile, error) {
 	// start by building some maps because everything
 	// is indexed in various maps.
 	// ----------------------
@@ -38,53 +71,156 @@ func NewProfile(p *pprof.Profile, mode sampleMode) (*Profile, error) {
 	// let's now build the profile
 	// ----------------------
 
-	typ := ReadProfileType(p)
+	sampleTypes := readSampleTypes(p, stringsMap)
 
-	if typ != "cpu" && typ != "space" {
-		return nil, fmt.Errorf("unsupported type: %s", typ)
+	idx, err := sampleIndex(p, stringsMap, sampleTypes, mode)
+	if err != nil {
+		return nil, err
 	}
 
-	profile := readProfile(p, stringsMap, functionsMapByLocation, locationsMap, mode)
+	bytesIdx, objectsIdx := objectSizesIndexes(sampleTypes, sampleTypes[idx])
 
-	switch typ {
-	case "cpu":
-		profile.Type = "cpu"
+	rules, err := profilePruneRules(p, stringsMap)
+	if err != nil {
+		return nil, err
+	}
+	// copy the rules as they're storing the cost they removed
+	rules = append(rules, pruneRules...)
+
+	profile := readProfile(p, stringsMap, functionsMapByLocation, locationsMap, idx, bytesIdx, objectsIdx, rules)
+	profile.PruneRules = rules
+	profile.SampleTypes = sampleTypes
+	profile.SampleType = sampleTypes[idx]
+	profile.HasObjectSizes = bytesIdx >= 0 && objectsIdx >= 0
+
+	switch typ := ReadProfileType(p); typ {
 	case "space":
 		profile.Type = "heap"
+	case "":
+		profile.Type = profile.SampleType.Type
+	default:
+		profile.Type = typ
 	}
 
 	return profile, nil
 }
 
+// NewProfileWithBase reads the profile and, if base isn't nil, the same
+// sample type in the base profile to display the differences between both.
+func NewProfileWithBase(p *pprof.Profile, base *pprof.Profile, mode sampleMode, pruneRules []pruneRule) (*Profile, error) {
+	profile, err := NewProfile(p, mode, pruneRules)
+	if err != nil {
+		return nil, err
+	}
+
+	if base != nil {
+		if profile.Base, err = NewProfile(base, sampleMode(profile.SampleType.Type), pruneRules); err != nil {
+			return nil, fmt.Errorf("base profile: %v", err)
+		}
+	}
+
+	return profile, nil
+}
+
+// ReadProfileType returns the period type of the profile, or the name of
+// its default sample type if it doesn't have any period type, as it is
+// optional and often missing in the non-Go profiles.
 func ReadProfileType(p *pprof.Profile) string {
-	return p.StringTable[uint64(p.GetPeriodType().Type)]
+	str := func(idx int64) string {
+		if idx < 0 || idx >= int64(len(p.StringTable)) {
+			return ""
+		}
+		return p.StringTable[idx]
+	}
+
+	if pt := p.GetPeriodType(); pt != nil {
+		return str(pt.GetType())
+	}
+
+	if typ := str(p.GetDefaultSampleType()); typ != "" {
+		return typ
+	}
+	if len(p.SampleType) > 0 {
+		return str(p.SampleType[len(p.SampleType)-1].GetType())
+	}
+	return ""
 }
 
-func readProfile(p *pprof.Profile, stringsMap StringsMap, functionsMapByLocation ManyFunctionsMap,
-	locationsMap LocationsMap, mode sampleMode) *Profile {
+func readSampleTypes(p *pprof.Profile, stringsMap StringsMap) []ValueType {
+	rv := make([]ValueType, len(p.SampleType))
+	for i, st := range p.SampleType {
+		rv[i] = ValueType{
+			Type: stringsMap[uint64(st.GetType())],
+			Unit: stringsMap[uint64(st.GetUnit())],
+		}
+	}
+	return rv
+}
 
-	var samples Samples
-	var idx int
-
-	switch {
-	case mode == ModeDefault:
-		fallthrough
-	case ReadProfileType(p) == "cpu" && mode == ModeCpu:
-		idx = 1
-	case ReadProfileType(p) == "space" && mode == ModeHeapAlloc:
-		idx = 1
-	case ReadProfileType(p) == "space" && mode == ModeHeapInuse:
-		idx = 3
+// sampleIndex returns the index of the value to read in the samples.
+// With ModeDefault, the default sample type of the profile is used, or
+// the last one if the profile doesn't set it, as pprof does.
+func sampleIndex(p *pprof.Profile, stringsMap StringsMap, sampleTypes []ValueType, mode sampleMode) (int, error) {
+	if len(sampleTypes) == 0 {
+		return 0, fmt.Errorf("sampleIndex: the profile doesn't have any sample type")
+	}
+
+	if mode == ModeDefault {
+		mode = sampleMode(stringsMap[uint64(p.GetDefaultSampleType())])
+		if mode == ModeDefault {
+			return len(sampleTypes) - 1, nil
+		}
+	}
+
+	for i, st := range sampleTypes {
+		if st.Type == string(mode) {
+			return i, nil
+		}
+	}
+
+	var available []string
+	for _, st := range sampleTypes {
+		available = append(available, st.Type)
+	}
+
+	return 0, fmt.Errorf("sampleIndex: unknown sample type %q, available: %v", mode, available)
+}
+
+// objectSizesIndexes returns the indexes of the bytes and objects count values
+// matching the given heap sample type, e.g. alloc_space and alloc_objects
+// for alloc_objects. -1 is returned for the values not available.
+func objectSizesIndexes(sampleTypes []ValueType, st ValueType) (bytesIdx int, objectsIdx int) {
+	bytesIdx, objectsIdx = -1, -1
+
+	var prefix string
+	switch sampleMode(st.Type) {
+	case ModeHeapAlloc, ModeHeapAllocObjects:
+		prefix = "alloc"
+	case ModeHeapInuse, ModeHeapInuseObjects:
+		prefix = "inuse"
 	default:
-		fmt.Printf("err: incompatible mode and profile type. %s & %s\n", ReadProfileType(p), mode)
-		os.Exit(-1)
+		return bytesIdx, objectsIdx
+	}
+
+	for i, t := range sampleTypes {
+		switch t.Type {
+		case prefix + "_space":
+			bytesIdx = i
+		case prefix + "_objects":
+			objectsIdx = i
+		}
 	}
 
+	return bytesIdx, objectsIdx
+}
+
+func readProfile(p *pprof.Profile, stringsMap StringsMap, functionsMapByLocation ManyFunctionsMap,
+	locationsMap LocationsMap, idx, bytesIdx, objectsIdx int, pruneRules []pruneRule) *Profile {
+
+	var samples Samples
+
 	for _, pprofSample := range p.Sample {
 		var sample Sample
-		// cpu [1] cpu usage
-		// space [1] heap allocated
-		// space [3] heap in use
 		value := pprofSample.GetValue()[idx]
 
 		for i := len(pprofSample.LocationId) - 1; i >= 0; i-- {
@@ -93,6 +229,34 @@ func readProfile(p *pprof.Profile, stringsMap StringsMap, functionsMapByLocation
 			sample.Value = value
 		}
 
+		for i := range pruneRules {
+			var pruned bool
+			if sample.Functions, pruned = pruneRules[i].prune(sample.Functions); pruned {
+				pruneRules[i].removed += value
+			}
+		}
+
+		if len(sample.Functions) == 0 {
+			continue
+		}
+
+		for _, l := range pprofSample.Label {
+			// as pprof does, a label is a numeric one when
+			// it has no string value but a number or a unit.
+			sample.Labels = append(sample.Labels, Label{
+				Key:     stringsMap[uint64(l.GetKey())],
+				Str:     stringsMap[uint64(l.GetStr())],
+				Num:     l.GetNum(),
+				NumUnit: stringsMap[uint64(l.GetNumUnit())],
+				Numeric: l.GetStr() == 0 && (l.GetNum() != 0 || l.GetNumUnit() != 0),
+			})
+		}
+
+		if bytesIdx >= 0 && objectsIdx >= 0 {
+			sample.Bytes = pprofSample.GetValue()[bytesIdx]
+			sample.Objects = pprofSample.GetValue()[objectsIdx]
+		}
+
 		// compute the self time for the leaf
 		leaf := sample.Functions[len(sample.Functions)-1]
 		leaf.Self += value
@@ -125,23 +289,116 @@ func readProfile(p *pprof.Profile, stringsMap StringsMap, functionsMapByLocation
 	}
 }
 
-func (p *Profile) BuildTree(treeName string, aggregateByFunction bool, searchField string) *FunctionsTree {
+// TreeOptions are the options used to build a FunctionsTree.
+type TreeOptions struct {
+	// aggregate per functions or per lines of code
+	AggregateByFunction bool
+	// only the nodes matching this (and their parents) are visible
+	SearchField string
+	// Inverted builds the tree from the leaves to the roots:
+	// the top level lists the functions by self cost, expanding
+	// them shows their callers.
+	Inverted bool
+	// Focus re-roots the tree at the first function, merging all its
+	// occurrences in every stack. The next functions are searched
+	// under the previous ones, building a focus path.
+	Focus []Function
+	// only the samples matching all the TagFocus filters and none
+	// of the TagIgnore filters are part of the tree.
+	TagFocus  []tagFilter
+	TagIgnore []tagFilter
+	// GroupBy is a label key, the tree has a root node per value of
+	// this label (and one for the samples without it).
+	GroupBy string
+	// CollapseRecursion collapses the recursive calls of a function,
+	// direct or indirect, in a single node.
+	CollapseRecursion bool
+}
+
+// stack returns the functions of the sample in the order they have
+// to be added to the tree, ok is false if the sample isn't part
+// of the tree built with these options.
+func (opts TreeOptions) stack(s Sample) (functions []Function, ok bool) {
+	if !opts.matchesTags(s.Labels) {
+		return nil, false
+	}
+
+	functions = s.Functions
+	if opts.CollapseRecursion {
+		functions = collapseRecursion(functions)
+	}
+	if opts.Inverted {
+		inverted := make([]Function, len(functions))
+		for i, f := range functions {
+			inverted[len(inverted)-1-i] = f
+		}
+		functions = inverted
+	}
+
+	if opts.GroupBy != "" {
+		functions = append([]Function{groupFunction(opts.GroupBy, s.Labels)}, functions...)
+	}
+
+	return focusStack(functions, opts.Focus)
+}
+
+// focusStack returns the part of the stack starting at the last function
+// of the focus path, ok is false if the stack doesn't contain the path.
+func focusStack(functions []Function, focus []Function) (rv []Function, ok bool) {
+	rv = functions
+	for _, focused := range focus {
+		found := false
+		for i, f := range rv {
+			if f.Name == focused.Name && f.File == focused.File {
+				rv = rv[i:]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return rv, true
+}
+
+func (p *Profile) BuildTree(treeName string, opts TreeOptions) *FunctionsTree {
 	// prepare the tree
 	tree := NewFunctionsTree(treeName)
 
+	samples := p.Samples
+	if p.Base != nil {
+		samples = p.diffSamples()
+	}
+
 	// fill the tree
-	for _, s := range p.Samples {
+	for _, s := range samples {
+		if s.Value == 0 {
+			continue
+		}
+
+		functions, ok := opts.stack(s)
+		if !ok {
+			continue
+		}
+
 		node := tree.root
-		for _, f := range s.Functions {
-			if s.Value == 0 {
-				continue
-			}
-			node = node.AddFunction(f, s.Value, f.Self, s.PercentTotal, aggregateByFunction)
+		for _, f := range functions {
+			node = node.AddFunction(f, s, opts.AggregateByFunction)
 		}
 	}
 
+	// the root holds the total of the tree
+	for _, child := range tree.root.children {
+		tree.root.value += child.value
+		tree.root.percent += child.percent
+		tree.root.bytes += child.bytes
+		tree.root.objects += child.objects
+		tree.root.base += child.base
+	}
+
 	if tree.root != nil {
-		tree.root.filter(searchField)
+		tree.root.filter(opts.SearchField)
 	}
 
 	tree.sort()
@@ -154,12 +411,22 @@ func buildLocationsMap(profile *pprof.Profile, stringsMap StringsMap, functionsM
 	lrv := make(ManyFunctionsMap)
 
 	for _, location := range profile.Location {
-		if location.Line[0] == nil {
+		loc := Location{}
+
+		// not symbolized, use the address as the function name
+		if len(location.Line) == 0 {
+			f := Function{Name: fmt.Sprintf("0x%x", location.GetAddress())}
+			for _, m := range profile.Mapping {
+				if m.GetId() == location.GetMappingId() {
+					f.File = stringsMap[uint64(m.GetFilename())]
+				}
+			}
+			loc.Functions = append(loc.Functions, f)
+			lrv[uint64(location.GetId())] = loc.Functions
+			rv[uint64(location.GetId())] = loc
 			continue
 		}
 
-		loc := Location{}
-
 		for idx := len(location.Line) - 1; idx >= 0; idx-- {
 			line := location.Line[idx]
 			inlined := idx != len(location.Line)-1
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// noPeriodTypeProfile returns a profile without period type, as
+// written by some non-Go profilers: a "samples" and a "wall" sample
+// types, 2 samples of the stack main -> work.
+func noPeriodTypeProfile() *pprof.Profile {
+	b := newProfileBuilder()
+	b.profile.SampleType = []*pprof.ValueType{
+		b.valueType("samples", "count"),
+		b.valueType("wall", "nanoseconds"),
+	}
+	b.profile.DefaultSampleType = b.str("wall")
+	stack := []uint64{b.frame("work", "work.c", 12), b.frame("main", "main.c", 3)}
+	b.profile.Sample = []*pprof.Sample{
+		{LocationId: stack, Value: []int64{1, 10}},
+		{LocationId: stack, Value: []int64{2, 20}},
+	}
+	return b.profile
+}
+
+func TestReadProfileType(t *testing.T) {
+	withoutDefault := noPeriodTypeProfile()
+	withoutDefault.DefaultSampleType = 0
+
+	withPeriod := noPeriodTypeProfile()
+	withPeriod.PeriodType = &pprof.ValueType{Type: 1, Unit: 2}
+
+	tests := []struct {
+		name    string
+		profile *pprof.Profile
+		want    string
+	}{
+		{"period type", withPeriod, "samples"},
+		{"default sample type", noPeriodTypeProfile(), "wall"},
+		{"last sample type", withoutDefault, "wall"},
+		{"empty profile", &pprof.Profile{}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := ReadProfileType(tt.profile); got != tt.want {
+			t.Errorf("%s: ReadProfileType() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewProfileWithoutPeriodType(t *testing.T) {
+	merged, err := mergeProfiles([]*pprof.Profile{noPeriodTypeProfile(), noPeriodTypeProfile()})
+	if err != nil {
+		t.Fatalf("mergeProfiles: %v", err)
+	}
+
+	for name, p := range map[string]*pprof.Profile{"single": noPeriodTypeProfile(), "merged": merged} {
+		profile, err := NewProfile(p, ModeDefault, nil)
+		if err != nil {
+			t.Fatalf("%s: NewProfile: %v", name, err)
+		}
+		if profile.Type != "wall" {
+			t.Errorf("%s: Type = %q, want %q", name, profile.Type, "wall")
+		}
+		if profile.SampleType.Type != "wall" {
+			t.Errorf("%s: SampleType = %q, want %q", name, profile.SampleType.Type, "wall")
+		}
+
+		want := uint64(30)
+		if name == "merged" {
+			want = 60
+		}
+		if profile.TotalSampling != want {
+			t.Errorf("%s: TotalSampling = %d, want %d", name, profile.TotalSampling, want)
+		}
+	}
+}
+
+func TestReadProfileLabels(t *testing.T) {
+	b := newProfileBuilder()
+	b.profile.SampleType = []*pprof.ValueType{b.valueType("samples", "count")}
+	b.profile.Sample = []*pprof.Sample{{
+		LocationId: []uint64{b.frame("main", "main.go", 3)},
+		Value:      []int64{1},
+		Label: []*pprof.Label{
+			{Key: b.str("route"), Str: b.str("/api")},
+			{Key: b.str("empty"), Str: b.str("")},
+			{Key: b.str("bytes"), Num: 2048, NumUnit: b.str("bytes")},
+			{Key: b.str("zero"), NumUnit: b.str("count")},
+			{Key: b.str("n"), Num: 5},
+		},
+	}}
+
+	profile, err := NewProfile(b.profile, ModeDefault, nil)
+	if err != nil {
+		t.Fatalf("NewProfile() error = %v", err)
+	}
+
+	want := map[string]bool{"route": false, "empty": false, "bytes": true, "zero": true, "n": true}
+	labels := profile.Samples[0].Labels
+	if len(labels) != len(want) {
+		t.Fatalf("Labels = %+v, want %d labels", labels, len(want))
+	}
+	for _, l := range labels {
+		if l.IsNumeric() != want[l.Key] {
+			t.Errorf("label %s IsNumeric() = %v, want %v", l.Key, l.IsNumeric(), want[l.Key])
+		}
+	}
+}
+
+func TestBuildTreeInverted(t *testing.T) {
+	profile := &Profile{
+		Samples: Samples{
+			stackSample(6, nil, "main", "a", "c"),
+			stackSample(2, nil, "main", "b", "c"),
+			stackSample(3, nil, "main", "e"),
+			stackSample(1, nil, "main"),
+		},
+		TotalSampling: 12,
+	}
+
+	tree := profile.BuildTree("test", TreeOptions{AggregateByFunction: true, Inverted: true})
+
+	// the leaf functions with their self cost first, then their callers
+	var got []string
+	var walk func(n *treeNode, depth int)
+	walk = func(n *treeNode, depth int) {
+		for _, child := range n.children {
+			got = append(got, fmt.Sprintf("%s%s %d/%d", strings.Repeat("  ", depth), child.function.Name, child.value, child.self))
+			walk(child, depth+1)
+		}
+	}
+	walk(tree.root, 0)
+
+	want := []string{
+		"c 8/8",
+		"  a 6/0",
+		"    main 6/0",
+		"  b 2/0",
+		"    main 2/0",
+		"e 3/3",
+		"  main 3/0",
+		"main 1/1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildTree() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if tree.root.value != 12 {
+		t.Errorf("BuildTree() root value = %d, want 12", tree.root.value)
+	}
+}
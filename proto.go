@@ -1,35 +1,233 @@
 package main
 
 import (
+	"bytes"
 	"compress/gzip"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"unicode/utf8"
 
 	"github.com/gogo/protobuf/proto"
+	"github.com/klauspost/compress/zstd"
 	"github.com/remeh/diago/pprof"
 )
 
+// inputFormat is the encoding of a profile, detected by sniffing its content.
+type inputFormat string
+
+const (
+	FormatGzip       inputFormat = "gzip"
+	FormatZstd       inputFormat = "zstd"
+	FormatProtobuf   inputFormat = "protobuf"
+	FormatFolded     inputFormat = "folded"
+	FormatPerf       inputFormat = "perf script"
+	FormatCpuProfile inputFormat = "cpuprofile"
+	FormatSpeedscope inputFormat = "speedscope"
+	FormatGoTrace    inputFormat = "go execution trace"
+
+	// formats which can be detected but not read,
+	// only used to provide a helpful error message.
+	FormatEmpty   inputFormat = "empty"
+	FormatJSON    inputFormat = "json"
+	FormatText    inputFormat = "text"
+	FormatUnknown inputFormat = "unknown binary"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// readProtoFile reads a profile from the given file, or from the
+// standard input if filename is "-".
 func readProtoFile(filename string) (*pprof.Profile, error) {
+	if filename == "-" {
+		profile, err := readProto(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("readProtoFile: stdin: %v", err)
+		}
+		return profile, nil
+	}
+
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("readProtoFile: os.Open: %v", err)
 	}
+	defer f.Close()
+
+	profile, err := readProto(f)
+	if err != nil {
+		return nil, fmt.Errorf("readProtoFile: %v", err)
+	}
+
+	return profile, nil
+}
+
+// readProto decodes a pprof profile, which may be compressed with gzip
+// or zstd, or not compressed at all. The profiles in other formats
+// (e.g. folded stacks) are converted to pprof profiles.
+func readProto(r io.Reader) (*pprof.Profile, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("readProto: ioutil.ReadAll: %v", err)
+	}
+
+	// uncompress as long as necessary, a zstd file could
+	// have been gzipped for transport for instance.
+	// ----------------------
+
+	for {
+		format := detectFormat(data)
+
+		switch format {
+		case FormatGzip:
+			if data, err = gunzip(data); err != nil {
+				return nil, fmt.Errorf("readProto: %v", err)
+			}
+		case FormatZstd:
+			if data, err = unzstd(data); err != nil {
+				return nil, fmt.Errorf("readProto: %v", err)
+			}
+		case FormatProtobuf:
+			var profile pprof.Profile
+			if err := proto.Unmarshal(data, &profile); err != nil {
+				return nil, fmt.Errorf("readProto: proto.Unmarshal: %v", err)
+			}
+			return &profile, nil
+		case FormatGoTrace:
+			profile, err := readGoTrace(data)
+			if err != nil {
+				return nil, fmt.Errorf("readProto: %v", err)
+			}
+			return profile, nil
+		case FormatSpeedscope:
+			profile, err := readSpeedscope(data)
+			if err != nil {
+				return nil, fmt.Errorf("readProto: %v", err)
+			}
+			return profile, nil
+		case FormatCpuProfile:
+			profile, err := readCpuProfile(data)
+			if err != nil {
+				return nil, fmt.Errorf("readProto: %v", err)
+			}
+			return profile, nil
+		case FormatPerf:
+			profile, err := readPerfScript(data)
+			if err != nil {
+				return nil, fmt.Errorf("readProto: %v", err)
+			}
+			return profile, nil
+		case FormatFolded:
+			profile, err := readFolded(data)
+			if err != nil {
+				return nil, fmt.Errorf("readProto: %v", err)
+			}
+			return profile, nil
+		default:
+			return nil, fmt.Errorf("readProto: unsupported input format: %s", format)
+		}
+	}
+}
+
+// detectFormat sniffs the given data to guess its encoding.
+func detectFormat(data []byte) inputFormat {
+	switch {
+	case len(data) == 0:
+		return FormatEmpty
+	case bytes.HasPrefix(data, gzipMagic):
+		return FormatGzip
+	case bytes.HasPrefix(data, zstdMagic):
+		return FormatZstd
+	case looksLikeGoTrace(data):
+		return FormatGoTrace
+	case looksLikeProtobuf(data):
+		return FormatProtobuf
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '['):
+		if looksLikeSpeedscope(trimmed) {
+			return FormatSpeedscope
+		}
+		if looksLikeCpuProfile(trimmed) {
+			return FormatCpuProfile
+		}
+		return FormatJSON
+	case looksLikePerfScript(data):
+		return FormatPerf
+	case looksLikeFolded(data):
+		return FormatFolded
+	case utf8.Valid(data) && !bytes.ContainsRune(data, 0):
+		return FormatText
+	}
+
+	return FormatUnknown
+}
+
+// looksLikeProtobuf walks the top-level fields of the given data and
+// returns true if they're all well-formed fields of a pprof Profile.
+func looksLikeProtobuf(data []byte) bool {
+	for len(data) > 0 {
+		key, n := proto.DecodeVarint(data)
+		if n == 0 {
+			return false
+		}
+		data = data[n:]
+
+		field, wireType := key>>3, key&0x7
+		// Profile only has the fields 1 to 14
+		if field < 1 || field > 14 {
+			return false
+		}
+
+		switch wireType {
+		case proto.WireVarint:
+			_, n := proto.DecodeVarint(data)
+			if n == 0 {
+				return false
+			}
+			data = data[n:]
+		case proto.WireBytes:
+			length, n := proto.DecodeVarint(data)
+			if n == 0 || uint64(len(data)-n) < length {
+				return false
+			}
+			data = data[uint64(n)+length:]
+		default:
+			return false
+		}
+	}
+	return true
+}
 
-	g, err := gzip.NewReader(f)
+func gunzip(data []byte) ([]byte, error) {
+	g, err := gzip.NewReader(bytes.NewReader(data))
 	if err != nil {
-		return nil, fmt.Errorf("readProtoFile: gzip.NewReader: %v", err)
+		return nil, fmt.Errorf("gzip.NewReader: %v", err)
 	}
+	defer g.Close()
 
-	data, err := ioutil.ReadAll(g)
+	rv, err := ioutil.ReadAll(g)
 	if err != nil {
-		return nil, fmt.Errorf("readProtoFile: ioutil.ReadAll: %v", err)
+		return nil, fmt.Errorf("gzip: ioutil.ReadAll: %v", err)
 	}
+	return rv, nil
+}
 
-	var profile pprof.Profile
-	if err := proto.Unmarshal(data, &profile); err != nil {
-		return nil, fmt.Errorf("readProtoFile: proto.Unmarshal: %v", err)
+func unzstd(data []byte) ([]byte, error) {
+	z, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, fmt.Errorf("zstd.NewReader: %v", err)
 	}
+	defer z.Close()
 
-	return &profile, nil
+	rv, err := z.DecodeAll(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("zstd: DecodeAll: %v", err)
+	}
+	return rv, nil
 }
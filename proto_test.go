@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/klauspost/compress/zstd"
+)
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdData(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	w, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	return w.EncodeAll(data, nil)
+}
+
+func TestDetectFormat(t *testing.T) {
+	raw, err := proto.Marshal(noPeriodTypeProfile())
+	if err != nil {
+		t.Fatalf("proto.Marshal() error = %v", err)
+	}
+
+	folded := []byte("main;work 3\n")
+	tests := []struct {
+		name string
+		data []byte
+		want inputFormat
+		// the stacks of the profile read from the data, nil if
+		// it can't be read.
+		stacks map[string]int64
+	}{
+		{"protobuf", raw, FormatProtobuf, map[string]int64{"main;work": 30}},
+		{"gzip", gzipData(t, raw), FormatGzip, map[string]int64{"main;work": 30}},
+		{"zstd", zstdData(t, raw), FormatZstd, map[string]int64{"main;work": 30}},
+		{"zstd in gzip", gzipData(t, zstdData(t, raw)), FormatGzip, map[string]int64{"main;work": 30}},
+		{"gzipped folded", gzipData(t, folded), FormatGzip, map[string]int64{"main;work": 3}},
+		{"folded", folded, FormatFolded, map[string]int64{"main;work": 3}},
+		{"perf", []byte(perfScript), FormatPerf, map[string]int64{"runtime.main;main.main;main.compute": 500000}},
+		{"cpuprofile", []byte(cpuProfileData), FormatCpuProfile, map[string]int64{"main;foo": 150, "main": 30, "(anonymous)": 20}},
+		{"speedscope", []byte(speedscopeData("")), FormatSpeedscope, map[string]int64{"main;foo": 4000000, "main;foo;bar": 2000000}},
+		{"go trace", []byte("go 1.23 trace\x00\x00\x00"), FormatGoTrace, nil},
+		{"json", []byte(` {"hello": "world"}`), FormatJSON, nil},
+		{"text", []byte("hello world\n"), FormatText, nil},
+		{"binary", []byte{0xff, 0x00, 0xfe, 0x01}, FormatUnknown, nil},
+		{"empty", nil, FormatEmpty, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := detectFormat(test.data); got != test.want {
+				t.Errorf("detectFormat() = %q, want %q", got, test.want)
+			}
+
+			p, err := readProto(bytes.NewReader(test.data))
+			if test.stacks == nil {
+				if err == nil {
+					t.Errorf("readProto() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readProto() error = %v", err)
+			}
+			equalStacks(t, foldedStacks(t, p, ModeDefault), test.stacks)
+		})
+	}
+}
+
+func TestReadProtoErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"json", []byte(`{"hello": "world"}`), "unsupported input format: json"},
+		{"text", []byte("hello world\n"), "unsupported input format: text"},
+		{"binary", []byte{0xff, 0x00, 0xfe, 0x01}, "unsupported input format: unknown binary"},
+		{"empty", nil, "unsupported input format: empty"},
+		{"truncated gzip", gzipData(t, []byte("main;work 3\n"))[:12], "readProto:"},
+		{"truncated zstd", zstdData(t, []byte("main;work 3\n"))[:6], "readProto:"},
+	}
+
+	for _, test := range tests {
+		_, err := readProto(bytes.NewReader(test.data))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: readProto() error = %v, want %q", test.name, err, test.err)
+		}
+	}
+}
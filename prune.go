@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// pruneKind is the way a prune rule removes the frames of a stack.
+type pruneKind string
+
+const (
+	// PruneDrop removes the first matching frame and all its callees,
+	// their cost is attributed to the caller of the dropped frame.
+	PruneDrop pruneKind = "drop"
+	// PruneIgnore removes the matching frames only, their cost is
+	// attributed to their callers.
+	PruneIgnore pruneKind = "ignore"
+)
+
+// prunedFunction replaces the stacks which frames have all been ignored,
+// to not remove their samples from the profile.
+const prunedFunction = "(pruned)"
+
+// pruneRule removes frames from the stacks of the samples.
+type pruneRule struct {
+	kind pruneKind
+	// description of the rule, e.g. "drop_frames: runtime\..*"
+	name string
+	re   *regexp.Regexp
+	// frames matching keep are never dropped, only set
+	// for the keep_frames of a profile.
+	keep *regexp.Regexp
+
+	// removed is the cost of the samples which stacks have been pruned
+	// by the rule, set when reading a profile. Their cost isn't removed
+	// from the profile, only attributed to other frames.
+	removed int64
+}
+
+func newPruneRule(kind pruneKind, expr string) (pruneRule, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return pruneRule{}, fmt.Errorf("newPruneRule: %v", err)
+	}
+	return pruneRule{kind: kind, name: fmt.Sprintf("%s: %s", kind, expr), re: re}, nil
+}
+
+func (r pruneRule) String() string {
+	return r.name
+}
+
+// profilePruneRules returns the rule dropping the drop_frames of the
+// profile, and not the keep_frames. As pprof does, the regular expressions
+// must match the whole function names.
+func profilePruneRules(p *pprof.Profile, stringsMap StringsMap) ([]pruneRule, error) {
+	drop := stringsMap[uint64(p.GetDropFrames())]
+	if drop == "" {
+		return nil, nil
+	}
+
+	rule := pruneRule{kind: PruneDrop, name: fmt.Sprintf("drop_frames: %s", drop)}
+
+	var err error
+	if rule.re, err = regexp.Compile("^(" + drop + ")$"); err != nil {
+		return nil, fmt.Errorf("profilePruneRules: drop_frames: %v", err)
+	}
+
+	if keep := stringsMap[uint64(p.GetKeepFrames())]; keep != "" {
+		if rule.keep, err = regexp.Compile("^(" + keep + ")$"); err != nil {
+			return nil, fmt.Errorf("profilePruneRules: keep_frames: %v", err)
+		}
+		rule.name += fmt.Sprintf(" (keep_frames: %s)", keep)
+	}
+
+	return []pruneRule{rule}, nil
+}
+
+// matches returns true if the frame of the function has to be pruned.
+func (r pruneRule) matches(f Function) bool {
+	name := strings.TrimPrefix(f.Name, "(inlined) ")
+	return r.re.MatchString(name) && (r.keep == nil || !r.keep.MatchString(name))
+}
+
+// prune returns the stack, the root first, without the frames removed
+// by the rule. changed is true if frames have been removed. A stack which
+// frames are all ignored is replaced by the prunedFunction frame.
+func (r pruneRule) prune(functions []Function) (rv []Function, changed bool) {
+	switch r.kind {
+	case PruneDrop:
+		// as pprof does, the frames before the first one not matching
+		// are never dropped, to not drop whole stacks.
+		foundUser := false
+		for i, f := range functions {
+			if !r.matches(f) {
+				foundUser = true
+				continue
+			}
+			if foundUser {
+				return functions[:i], true
+			}
+		}
+		return functions, false
+
+	case PruneIgnore:
+		for i, f := range functions {
+			if !r.matches(f) {
+				if changed {
+					rv = append(rv, f)
+				}
+				continue
+			}
+			if !changed {
+				rv = append([]Function{}, functions[:i]...)
+				changed = true
+			}
+		}
+		if !changed {
+			return functions, false
+		}
+		if len(rv) == 0 {
+			return []Function{{Name: prunedFunction}}, true
+		}
+		return rv, true
+	}
+
+	return functions, false
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// stackFunctions returns the stack of functions named after the frames,
+// the root first, separated by semicolons.
+func stackFunctions(stack string) []Function {
+	var rv []Function
+	for _, name := range strings.Split(stack, ";") {
+		rv = append(rv, Function{Name: name})
+	}
+	return rv
+}
+
+// stackNames returns the names of the functions separated by semicolons.
+func stackNames(functions []Function) string {
+	names := make([]string, len(functions))
+	for i, f := range functions {
+		names[i] = f.Name
+	}
+	return strings.Join(names, ";")
+}
+
+func TestPruneRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    pruneKind
+		expr    string
+		keep    string
+		stack   string
+		want    string
+		changed bool
+	}{
+		{
+			name:    "drop the callees",
+			kind:    PruneDrop,
+			expr:    `^runtime\.`,
+			stack:   "main;work;runtime.mallocgc;runtime.memclr",
+			want:    "main;work",
+			changed: true,
+		},
+		{
+			name:  "drop without match",
+			kind:  PruneDrop,
+			expr:  `^runtime\.`,
+			stack: "main;work",
+			want:  "main;work",
+		},
+		{
+			name:  "drop never removes the root frames",
+			kind:  PruneDrop,
+			expr:  `^runtime\.`,
+			stack: "runtime.goexit;runtime.gcBgMarkWorker",
+			want:  "runtime.goexit;runtime.gcBgMarkWorker",
+		},
+		{
+			name:    "drop after the root frames",
+			kind:    PruneDrop,
+			expr:    `^runtime\.`,
+			stack:   "runtime.goexit;main;runtime.mallocgc",
+			want:    "runtime.goexit;main",
+			changed: true,
+		},
+		{
+			name:    "drop an inlined frame",
+			kind:    PruneDrop,
+			expr:    `^bytes\.`,
+			stack:   "main;(inlined) bytes.Compare;cmpbody",
+			want:    "main",
+			changed: true,
+		},
+		{
+			name:  "drop but keep",
+			kind:  PruneDrop,
+			expr:  `^runtime\..*`,
+			keep:  `^runtime\.mallocgc$`,
+			stack: "main;runtime.mallocgc",
+			want:  "main;runtime.mallocgc",
+		},
+		{
+			name:    "ignore the frames",
+			kind:    PruneIgnore,
+			expr:    `^middleware\.`,
+			stack:   "main;middleware.Auth;middleware.Log;handler;middleware.Recover;work",
+			want:    "main;handler;work",
+			changed: true,
+		},
+		{
+			name:    "ignore the leaf",
+			kind:    PruneIgnore,
+			expr:    `^work$`,
+			stack:   "main;work",
+			want:    "main",
+			changed: true,
+		},
+		{
+			name:    "ignore every frame",
+			kind:    PruneIgnore,
+			expr:    `^(main|work)$`,
+			stack:   "main;work",
+			want:    prunedFunction,
+			changed: true,
+		},
+		{
+			name:  "ignore without match",
+			kind:  PruneIgnore,
+			expr:  `^middleware\.`,
+			stack: "main;work",
+			want:  "main;work",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := newPruneRule(test.kind, test.expr)
+			if err != nil {
+				t.Fatalf("newPruneRule() error = %v", err)
+			}
+			if test.keep != "" {
+				rule.keep = regexp.MustCompile(test.keep)
+			}
+
+			original := stackFunctions(test.stack)
+			rv, changed := rule.prune(original)
+			if got := stackNames(rv); got != test.want || changed != test.changed {
+				t.Errorf("prune(%q) = %q, %v, want %q, %v", test.stack, got, changed, test.want, test.changed)
+			}
+			if got := stackNames(original); got != test.stack {
+				t.Errorf("prune(%q) modified the stack: %q", test.stack, got)
+			}
+		})
+	}
+}
+
+func TestProfilePruneRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		drop  string
+		keep  string
+		want  string
+		stack string
+		err   string
+	}{
+		{name: "without drop_frames", stack: "main;runtime.mallocgc;runtime.memclr", want: "main;runtime.mallocgc;runtime.memclr"},
+		{name: "whole names", drop: `runtime\.malloc`, stack: "main;runtime.mallocgc", want: "main;runtime.mallocgc"},
+		{name: "drop_frames", drop: `runtime\..*`, stack: "main;runtime.mallocgc;runtime.memclr", want: "main"},
+		{name: "alternatives", drop: `a|runtime\..*`, stack: "main;runtime.mallocgc", want: "main"},
+		{name: "keep_frames", drop: `runtime\..*`, keep: `runtime\.mallocgc`, stack: "main;runtime.mallocgc;runtime.memclr", want: "main;runtime.mallocgc"},
+		{name: "invalid drop_frames", drop: `(`, err: "drop_frames"},
+		{name: "invalid keep_frames", drop: `a`, keep: `(`, err: "keep_frames"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newProfileBuilder()
+			if test.drop != "" {
+				b.profile.DropFrames = b.str(test.drop)
+			}
+			if test.keep != "" {
+				b.profile.KeepFrames = b.str(test.keep)
+			}
+
+			rules, err := profilePruneRules(b.profile, buildStringsTable(b.profile))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("profilePruneRules() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("profilePruneRules() error = %v", err)
+			}
+
+			rv := stackFunctions(test.stack)
+			for _, rule := range rules {
+				rv, _ = rule.prune(rv)
+			}
+			if got := stackNames(rv); got != test.want {
+				t.Errorf("prune(%q) = %q, want %q", test.stack, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewProfilePruneRules(t *testing.T) {
+	p, err := readFolded([]byte("main;work 3\nmain;other 2\n"))
+	if err != nil {
+		t.Fatalf("readFolded() error = %v", err)
+	}
+	rule, err := newPruneRule(PruneIgnore, `^(main|work)$`)
+	if err != nil {
+		t.Fatalf("newPruneRule() error = %v", err)
+	}
+
+	profile, err := NewProfile(p, ModeDefault, []pruneRule{rule})
+	if err != nil {
+		t.Fatalf("NewProfile() error = %v", err)
+	}
+
+	if profile.TotalSampling != 5 {
+		t.Errorf("TotalSampling = %d, want 5", profile.TotalSampling)
+	}
+	var stacks []string
+	for _, s := range profile.Samples {
+		stacks = append(stacks, fmt.Sprintf("%s %d", stackNames(s.Functions), s.Value))
+	}
+	sort.Strings(stacks)
+	if got, want := strings.Join(stacks, ","), "(pruned) 3,other 2"; got != want {
+		t.Errorf("stacks = %q, want %q", got, want)
+	}
+
+	texts := profile.pruneTexts()
+	if want := "ignore: ^(main|work)$ - frames pruned from 5 (100.00%) of samples"; len(texts) != 1 || texts[0] != want {
+		t.Errorf("pruneTexts() = %q, want [%q]", texts, want)
+	}
+}
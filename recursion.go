@@ -0,0 +1,47 @@
+package main
+
+// collapseRecursion returns the stack, the root first, with the direct
+// and indirect recursions collapsed: when a function is called again by
+// one of its callees, the frames between both calls are removed, e.g.
+// A→A→A becomes A and A→B→A→B→C becomes A→B→C. The Recursion of a frame
+// is the number of calls of its function collapsed in it.
+func collapseRecursion(functions []Function) []Function {
+	rv := make([]Function, 0, len(functions))
+	positions := make(map[string]int)
+	calls := make(map[string]int)
+
+	var self int64
+	for _, f := range functions {
+		self += f.Self
+		f.Self = 0
+
+		key := recursionKey(f)
+		calls[key]++
+
+		// called again, remove the frames called since the previous call
+		if i, ok := positions[key]; ok {
+			for _, removed := range rv[i+1:] {
+				delete(positions, recursionKey(removed))
+			}
+			rv = rv[:i+1]
+			rv[i].Recursion = calls[key]
+			continue
+		}
+
+		f.Recursion = calls[key]
+		positions[key] = len(rv)
+		rv = append(rv, f)
+	}
+
+	// the self value belongs to the new leaf
+	if len(rv) > 0 {
+		rv[len(rv)-1].Self = self
+	}
+
+	return rv
+}
+
+// recursionKey identifies the function of a frame, whatever its line.
+func recursionKey(f Function) string {
+	return f.Name + "\x00" + f.File
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollapseRecursion(t *testing.T) {
+	tests := []struct {
+		name       string
+		functions  []Function
+		want       string
+		recursions []int
+	}{
+		{
+			name:       "no recursion",
+			functions:  stackFunctions("main;a;b;c"),
+			want:       "main;a;b;c",
+			recursions: []int{1, 1, 1, 1},
+		},
+		{
+			name:       "direct recursion",
+			functions:  stackFunctions("main;a;a;a"),
+			want:       "main;a",
+			recursions: []int{1, 3},
+		},
+		{
+			name:       "indirect recursion",
+			functions:  stackFunctions("a;b;a;b;c"),
+			want:       "a;b;c",
+			recursions: []int{2, 2, 1},
+		},
+		{
+			name:       "recursion in the leaf",
+			functions:  stackFunctions("main;walk;visit;walk"),
+			want:       "main;walk",
+			recursions: []int{1, 2},
+		},
+		{
+			name:       "several recursions",
+			functions:  stackFunctions("main;a;a;b;c;b;d"),
+			want:       "main;a;b;d",
+			recursions: []int{1, 2, 2, 1},
+		},
+		{
+			name:       "different lines",
+			functions:  []Function{{Name: "a", LineNumber: 10}, {Name: "a", LineNumber: 12}},
+			want:       "a",
+			recursions: []int{2},
+		},
+		{
+			name:       "different files",
+			functions:  []Function{{Name: "init", File: "a.go"}, {Name: "init", File: "b.go"}},
+			want:       "init;init",
+			recursions: []int{1, 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.functions[len(test.functions)-1].Self = 7
+			original := append([]Function{}, test.functions...)
+
+			rv := collapseRecursion(test.functions)
+			if got := stackNames(rv); got != test.want {
+				t.Fatalf("collapseRecursion() = %q, want %q", got, test.want)
+			}
+
+			recursions := make([]int, len(rv))
+			for i, f := range rv {
+				recursions[i] = f.Recursion
+				self := int64(0)
+				if i == len(rv)-1 {
+					self = 7
+				}
+				if f.Self != self {
+					t.Errorf("collapseRecursion() %s self = %d, want %d", f.Name, f.Self, self)
+				}
+			}
+			if !reflect.DeepEqual(recursions, test.recursions) {
+				t.Errorf("collapseRecursion() recursions = %v, want %v", recursions, test.recursions)
+			}
+			if !reflect.DeepEqual(test.functions, original) {
+				t.Errorf("collapseRecursion() modified the stack")
+			}
+		})
+	}
+}
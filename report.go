@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/remeh/diago/pprof"
+)
+
+// report prints the tree or the flat top table of the profile
+// on the standard output, without opening the GUI.
+func report(pprofProfile *pprof.Profile, base *pprof.Profile) error {
+	profile, err := NewProfileWithBase(pprofProfile, base, sampleMode(config.SampleType), config.PruneRules)
+	if err != nil {
+		return fmt.Errorf("report: %v", err)
+	}
+
+	opts := config.TreeOptions()
+
+	if config.Top > 0 {
+		sortBy := topSort(config.TopSort)
+		if sortBy != TopSortFlat && sortBy != TopSortCum && sortBy != TopSortName {
+			return fmt.Errorf("report: unknown top sort %q", config.TopSort)
+		}
+		entries := profile.Top(opts, sortBy)
+		return writeTextTop(os.Stdout, profile, config.Source(), entries, config.Top, opts)
+	}
+
+	tree := profile.BuildTree(config.Source(), opts)
+	return writeTextTree(os.Stdout, profile, tree, opts, config.Depth, config.NodeFraction)
+}
+
+// writeTextTree writes the tree indented, one node per line. The nodes deeper
+// than maxDepth (0 for no limit) or which values are less than nodeFraction
+// of the total are not written.
+func writeTextTree(w io.Writer, profile *Profile, tree *FunctionsTree, opts TreeOptions, maxDepth int, nodeFraction float64) error {
+	b := bufio.NewWriter(w)
+	unit := profile.SampleType.Unit
+	format := formatValue
+	if profile.Base != nil {
+		format = formatDelta
+	}
+
+	total := int64(profile.TotalSampling)
+	if profile.Base != nil {
+		total = int64(profile.diffTotal())
+	}
+	minValue := int64(nodeFraction * float64(total))
+
+	fmt.Fprintln(b, profile.headerText(tree.name, opts))
+	for _, text := range profile.pruneTexts() {
+		fmt.Fprintln(b, text)
+	}
+	fmt.Fprintf(b, "%9s %12s %12s  %s\n", "percent", "value", "self", "function")
+
+	var hidden int
+	var write func(n *treeNode, depth int)
+	write = func(n *treeNode, depth int) {
+		for _, child := range n.children {
+			if !child.visible {
+				continue
+			}
+			if abs(child.value) < minValue || (maxDepth > 0 && depth >= maxDepth) {
+				hidden += child.visibleNodes()
+				continue
+			}
+
+			name := textFunctionName(child.function, opts.AggregateByFunction)
+			if child.recursion > 1 {
+				name += fmt.Sprintf(" (recursion depth: %d)", child.recursion)
+			}
+			fmt.Fprintf(b, "%8.2f%% %12s %12s  %s%s\n", child.percent, format(child.value, unit), format(child.self, unit),
+				strings.Repeat("  ", depth), name)
+			write(child, depth+1)
+		}
+	}
+	write(tree.root, 0)
+
+	if hidden > 0 {
+		fmt.Fprintf(b, "(%d %s hidden by the depth or node fraction limits)\n", hidden, plural(hidden, "node"))
+	}
+
+	return b.Flush()
+}
+
+// writeTextTop writes the n first entries of the flat top table.
+func writeTextTop(w io.Writer, profile *Profile, name string, entries []topEntry, n int, opts TreeOptions) error {
+	b := bufio.NewWriter(w)
+	unit := profile.SampleType.Unit
+	format := formatValue
+	if profile.Base != nil {
+		format = formatDelta
+	}
+
+	fmt.Fprintln(b, profile.headerText(name, opts))
+	for _, text := range profile.pruneTexts() {
+		fmt.Fprintln(b, text)
+	}
+	fmt.Fprintf(b, "%12s %8s %8s %12s %8s  %s\n", "flat", "flat%", "sum%", "cum", "cum%", "function")
+
+	for i, e := range entries {
+		if i >= n {
+			fmt.Fprintf(b, "(%d more %s)\n", len(entries)-n, plural(len(entries)-n, "entry"))
+			break
+		}
+		fmt.Fprintf(b, "%12s %7.2f%% %7.2f%% %12s %7.2f%%  %s\n", format(e.flat, unit), e.flatPercent, e.sumPercent,
+			format(e.cum, unit), e.cumPercent, textFunctionName(e.function, opts.AggregateByFunction))
+	}
+
+	return b.Flush()
+}
+
+func textFunctionName(f Function, aggregateByFunction bool) string {
+	location := fileBase(f.File)
+	if !aggregateByFunction && (location != "" || f.LineNumber != 0) {
+		location = fmt.Sprintf("%s:%d", location, f.LineNumber)
+	}
+	if location == "" {
+		return f.Name
+	}
+	return fmt.Sprintf("%s %s", f.Name, location)
+}
+
+// visibleNodes returns the number of visible nodes of
+// the subtree of the node, the node included.
+func (n *treeNode) visibleNodes() int {
+	if !n.visible {
+		return 0
+	}
+	count := 1
+	for _, child := range n.children {
+		count += child.visibleNodes()
+	}
+	return count
+}
+
+// plural returns the noun in the plural if count isn't 1.
+func plural(count int, noun string) string {
+	switch {
+	case count == 1:
+		return noun
+	case strings.HasSuffix(noun, "y"):
+		return strings.TrimSuffix(noun, "y") + "ies"
+	}
+	return noun + "s"
+}
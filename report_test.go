@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteTextTree(t *testing.T) {
+	folded := "main;a;b;c 6\nmain;a;d 1\nmain;e 3\n"
+
+	tests := []struct {
+		name         string
+		opts         TreeOptions
+		search       string
+		maxDepth     int
+		nodeFraction float64
+		want         string
+	}{
+		{
+			name: "tree",
+			want: "test - total samples: 10\n" +
+				"  percent        value         self  function\n" +
+				"  100.00%           10            0  main\n" +
+				"   70.00%            7            0    a\n" +
+				"   60.00%            6            0      b\n" +
+				"   60.00%            6            6        c\n" +
+				"   10.00%            1            1      d\n" +
+				"   30.00%            3            3    e\n",
+		},
+		{
+			name:         "hidden subtrees",
+			maxDepth:     2,
+			nodeFraction: 0.2,
+			want: "test - total samples: 10\n" +
+				"  percent        value         self  function\n" +
+				"  100.00%           10            0  main\n" +
+				"   70.00%            7            0    a\n" +
+				"   30.00%            3            3    e\n" +
+				"(3 nodes hidden by the depth or node fraction limits)\n",
+		},
+		{
+			name:     "one hidden node",
+			maxDepth: 2,
+			search:   "d",
+			want: "test - total samples: 10\n" +
+				"  percent        value         self  function\n" +
+				"  100.00%           10            0  main\n" +
+				"   70.00%            7            0    a\n" +
+				"(1 node hidden by the depth or node fraction limits)\n",
+		},
+		{
+			name:         "inverted",
+			opts:         TreeOptions{Inverted: true},
+			maxDepth:     2,
+			nodeFraction: 0.2,
+			want: "test - total samples: 10 - inverted\n" +
+				"  percent        value         self  function\n" +
+				"   60.00%            6            6  c\n" +
+				"   60.00%            6            0    b\n" +
+				"   30.00%            3            3  e\n" +
+				"   30.00%            3            0    main\n" +
+				"(5 nodes hidden by the depth or node fraction limits)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, tree := foldedTree(t, folded, "", tt.opts)
+			if tt.search != "" {
+				tree.root.filter(tt.search)
+			}
+			tt.opts.AggregateByFunction = true
+			var buf bytes.Buffer
+			if err := writeTextTree(&buf, profile, tree, tt.opts, tt.maxDepth, tt.nodeFraction); err != nil {
+				t.Fatalf("writeTextTree() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writeTextTree() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteTextTreeGroupBy(t *testing.T) {
+	profile := &Profile{
+		Samples: Samples{
+			stackSample(4, []Label{{Key: "k", Str: "v1"}}, "main", "work"),
+			stackSample(2, []Label{{Key: "k", Str: "v2"}}, "main"),
+		},
+		TotalSampling: 6,
+		SampleType:    ValueType{Type: "samples", Unit: "count"},
+	}
+	for i := range profile.Samples {
+		profile.Samples[i].PercentTotal = float64(profile.Samples[i].Value) / 6 * 100
+	}
+	profile.Samples[0].Functions[1].File = "/src/work.go"
+	profile.Samples[0].Functions[1].LineNumber = 12
+
+	opts := TreeOptions{GroupBy: "k"}
+	tree := profile.BuildTree("test", opts)
+	var buf bytes.Buffer
+	if err := writeTextTree(&buf, profile, tree, opts, 0, 0); err != nil {
+		t.Fatalf("writeTextTree() error = %v", err)
+	}
+	want := "test - total samples: 6 - grouped by k\n" +
+		"  percent        value         self  function\n" +
+		"   66.67%            4            0  k=v1\n" +
+		"   66.67%            4            0    main\n" +
+		"   66.67%            4            4      work work.go:12\n" +
+		"   33.33%            2            0  k=v2\n" +
+		"   33.33%            2            2    main\n"
+	if got := buf.String(); got != want {
+		t.Errorf("writeTextTree() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTextTop(t *testing.T) {
+	folded := "main;a;b;c 6\nmain;a;d 1\nmain;e 3\n"
+	opts := TreeOptions{AggregateByFunction: true}
+
+	tests := []struct {
+		name string
+		n    int
+		want string
+	}{
+		{
+			name: "all",
+			n:    6,
+			want: "test - total samples: 10\n" +
+				"        flat    flat%     sum%          cum     cum%  function\n" +
+				"           6   60.00%   60.00%            6   60.00%  c\n" +
+				"           3   30.00%   90.00%            3   30.00%  e\n" +
+				"           1   10.00%  100.00%            1   10.00%  d\n" +
+				"           0    0.00%  100.00%            7   70.00%  a\n" +
+				"           0    0.00%  100.00%            6   60.00%  b\n" +
+				"           0    0.00%  100.00%           10  100.00%  main\n",
+		},
+		{
+			name: "more entries",
+			n:    2,
+			want: "test - total samples: 10\n" +
+				"        flat    flat%     sum%          cum     cum%  function\n" +
+				"           6   60.00%   60.00%            6   60.00%  c\n" +
+				"           3   30.00%   90.00%            3   30.00%  e\n" +
+				"(4 more entries)\n",
+		},
+		{
+			name: "one more entry",
+			n:    5,
+			want: "test - total samples: 10\n" +
+				"        flat    flat%     sum%          cum     cum%  function\n" +
+				"           6   60.00%   60.00%            6   60.00%  c\n" +
+				"           3   30.00%   90.00%            3   30.00%  e\n" +
+				"           1   10.00%  100.00%            1   10.00%  d\n" +
+				"           0    0.00%  100.00%            7   70.00%  a\n" +
+				"           0    0.00%  100.00%            6   60.00%  b\n" +
+				"(1 more entry)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, _ := foldedTree(t, folded, "", opts)
+			var buf bytes.Buffer
+			if err := writeTextTop(&buf, profile, "test", profile.Top(opts, TopSortFlat), tt.n, opts); err != nil {
+				t.Fatalf("writeTextTop() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writeTextTop() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/remeh/diago/pprof"
+)
+
+const speedscopeSchema = "https://www.speedscope.app/file-format-schema.json"
+
+// speedscopeFile is a file in the speedscope format, see
+// https://github.com/jlfwong/speedscope/wiki/Importing-from-custom-sources
+type speedscopeFile struct {
+	Schema             string              `json:"$schema"`
+	Shared             speedscopeShared    `json:"shared"`
+	Profiles           []speedscopeProfile `json:"profiles"`
+	Name               string              `json:"name,omitempty"`
+	ActiveProfileIndex *int                `json:"activeProfileIndex,omitempty"`
+	Exporter           string              `json:"exporter,omitempty"`
+}
+
+type speedscopeShared struct {
+	Frames []speedscopeFrame `json:"frames"`
+}
+
+type speedscopeFrame struct {
+	Name string `json:"name"`
+	File string `json:"file,omitempty"`
+	Line int64  `json:"line,omitempty"`
+	Col  int64  `json:"col,omitempty"`
+}
+
+type speedscopeProfile struct {
+	Type       string  `json:"type"`
+	Name       string  `json:"name"`
+	Unit       string  `json:"unit"`
+	StartValue float64 `json:"startValue"`
+	EndValue   float64 `json:"endValue"`
+
+	// evented profiles
+	Events []speedscopeEvent `json:"events,omitempty"`
+
+	// sampled profiles, the stacks are the root first
+	Samples [][]int   `json:"samples,omitempty"`
+	Weights []float64 `json:"weights,omitempty"`
+}
+
+type speedscopeEvent struct {
+	// Type is "O" when a frame is opened, "C" when it is closed.
+	Type  string  `json:"type"`
+	Frame int     `json:"frame"`
+	At    float64 `json:"at"`
+}
+
+// looksLikeSpeedscope returns true if the JSON data is a speedscope file.
+func looksLikeSpeedscope(data []byte) bool {
+	var probe struct {
+		Schema string `json:"$schema"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	return probe.Schema == speedscopeSchema
+}
+
+// readSpeedscope converts a speedscope file to a pprof profile. All its
+// profiles are merged, the name of each being kept as the "profile" label
+// of its samples. There is a sample type per kind of unit: "time" in
+// nanoseconds, "space" in bytes and "samples" for the profiles without unit.
+func readSpeedscope(data []byte) (*pprof.Profile, error) {
+	var file speedscopeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("readSpeedscope: json.Unmarshal: %v", err)
+	}
+
+	b := newProfileBuilder()
+	sampleTypes := make(map[string]int)
+	sampleTypeIndex := func(unit string) (int, float64, error) {
+		typ, pprofUnit, factor := "samples", "count", 1.0
+		if d, ok := durationUnits[unit]; ok {
+			typ, pprofUnit, factor = "time", "nanoseconds", float64(d)
+		} else if unit == "bytes" {
+			typ, pprofUnit = "space", "bytes"
+		} else if unit != "none" && unit != "" {
+			return 0, 0, fmt.Errorf("unknown unit %q", unit)
+		}
+		idx, ok := sampleTypes[typ]
+		if !ok {
+			idx = len(b.profile.SampleType)
+			sampleTypes[typ] = idx
+			b.profile.SampleType = append(b.profile.SampleType, b.valueType(typ, pprofUnit))
+		}
+		return idx, factor, nil
+	}
+
+	locations := make([]uint64, len(file.Shared.Frames))
+	for i, f := range file.Shared.Frames {
+		locations[i] = b.frame(f.Name, f.File, f.Line)
+	}
+	location := func(frame int) (uint64, error) {
+		if frame < 0 || frame >= len(locations) {
+			return 0, fmt.Errorf("unknown frame %d", frame)
+		}
+		return locations[frame], nil
+	}
+
+	type stackValue struct {
+		locations []uint64
+		value     float64
+	}
+
+	for i, p := range file.Profiles {
+		idx, factor, err := sampleTypeIndex(p.Unit)
+		if err != nil {
+			return nil, fmt.Errorf("readSpeedscope: profile #%d: %v", i, err)
+		}
+
+		// aggregate the values of the same stacks, the leaf first
+		var stacks []*stackValue
+		stacksByKey := make(map[string]*stackValue)
+		add := func(frames []int, value float64) error {
+			s := make([]uint64, len(frames))
+			for j, frame := range frames {
+				id, err := location(frame)
+				if err != nil {
+					return err
+				}
+				s[len(frames)-1-j] = id
+			}
+			key := fmt.Sprint(s)
+			if v, ok := stacksByKey[key]; ok {
+				v.value += value
+				return nil
+			}
+			v := &stackValue{locations: s, value: value}
+			stacksByKey[key] = v
+			stacks = append(stacks, v)
+			return nil
+		}
+
+		switch p.Type {
+		case "sampled":
+			for j, frames := range p.Samples {
+				weight := 1.0
+				if j < len(p.Weights) {
+					weight = p.Weights[j]
+				}
+				if err := add(frames, weight); err != nil {
+					return nil, fmt.Errorf("readSpeedscope: profile #%d: %v", i, err)
+				}
+			}
+		case "evented":
+			// the time between two events is spent in the opened frames
+			var opened []int
+			at := p.StartValue
+			for _, e := range p.Events {
+				if len(opened) > 0 && e.At > at {
+					if err := add(opened, e.At-at); err != nil {
+						return nil, fmt.Errorf("readSpeedscope: profile #%d: %v", i, err)
+					}
+				}
+				at = e.At
+
+				switch e.Type {
+				case "O":
+					opened = append(opened, e.Frame)
+				case "C":
+					if len(opened) == 0 || opened[len(opened)-1] != e.Frame {
+						return nil, fmt.Errorf("readSpeedscope: profile #%d: frame %d closed but not opened", i, e.Frame)
+					}
+					opened = opened[:len(opened)-1]
+				default:
+					return nil, fmt.Errorf("readSpeedscope: profile #%d: unknown event type %q", i, e.Type)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("readSpeedscope: profile #%d: unknown profile type %q", i, p.Type)
+		}
+
+		for _, s := range stacks {
+			sample := &pprof.Sample{
+				LocationId: s.locations,
+				Label:      []*pprof.Label{{Key: b.str("profile"), Str: b.str(p.Name)}},
+			}
+			sample.Value = make([]int64, idx+1)
+			sample.Value[idx] = int64(s.value * factor)
+			b.profile.Sample = append(b.profile.Sample, sample)
+		}
+	}
+
+	if len(b.profile.SampleType) == 0 {
+		return nil, fmt.Errorf("readSpeedscope: no profiles")
+	}
+
+	// the sample types are known once all the profiles are read
+	for _, s := range b.profile.Sample {
+		for len(s.Value) < len(b.profile.SampleType) {
+			s.Value = append(s.Value, 0)
+		}
+	}
+
+	// display the active profile first
+	active := 0
+	if file.ActiveProfileIndex != nil && *file.ActiveProfileIndex >= 0 && *file.ActiveProfileIndex < len(file.Profiles) {
+		active = *file.ActiveProfileIndex
+	}
+	idx, _, _ := sampleTypeIndex(file.Profiles[active].Unit)
+	b.profile.DefaultSampleType = b.profile.SampleType[idx].Type
+	b.profile.PeriodType = b.profile.SampleType[idx]
+
+	return b.profile, nil
+}
+
+// writeSpeedscope writes the samples of the profile, with the given
+// options, as a speedscope file containing a single sampled profile.
+func writeSpeedscope(w io.Writer, profile *Profile, name string, opts TreeOptions) error {
+	if profile.Base != nil {
+		return fmt.Errorf("writeSpeedscope: the differential view can't be exported to speedscope")
+	}
+
+	unit := profile.SampleType.Unit
+	if _, ok := durationUnits[unit]; !ok && unit != "bytes" {
+		unit = "none"
+	}
+
+	sp := speedscopeProfile{
+		Type:    "sampled",
+		Name:    fmt.Sprintf("%s - %s", name, profile.SampleType.Type),
+		Unit:    unit,
+		Samples: [][]int{},
+		Weights: []float64{},
+	}
+
+	var frames []speedscopeFrame
+	frameIndexes := make(map[speedscopeFrame]int)
+
+	for _, s := range profile.Samples {
+		if s.Value == 0 {
+			continue
+		}
+		functions, ok := opts.stack(s)
+		if !ok || !stackMatches(functions, opts.SearchField) {
+			continue
+		}
+
+		stack := make([]int, len(functions))
+		for i, f := range functions {
+			frame := speedscopeFrame{Name: f.Name, File: f.File}
+			if !opts.AggregateByFunction {
+				frame.Line = int64(f.LineNumber)
+			}
+			idx, ok := frameIndexes[frame]
+			if !ok {
+				idx = len(frames)
+				frameIndexes[frame] = idx
+				frames = append(frames, frame)
+			}
+			stack[i] = idx
+		}
+
+		sp.Samples = append(sp.Samples, stack)
+		sp.Weights = append(sp.Weights, float64(s.Value))
+		sp.EndValue += float64(s.Value)
+	}
+
+	if frames == nil {
+		frames = []speedscopeFrame{}
+	}
+
+	activeProfileIndex := 0
+	file := speedscopeFile{
+		Schema:             speedscopeSchema,
+		Shared:             speedscopeShared{Frames: frames},
+		Profiles:           []speedscopeProfile{sp},
+		Name:               name,
+		ActiveProfileIndex: &activeProfileIndex,
+		Exporter:           "diago",
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(file); err != nil {
+		return fmt.Errorf("writeSpeedscope: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// speedscopeData returns a speedscope file with a sampled profile in
+// milliseconds and an evented profile without unit.
+func speedscopeData(activeProfileIndex string) string {
+	return fmt.Sprintf(`{
+	"$schema": "https://www.speedscope.app/file-format-schema.json",
+	"shared": {"frames": [{"name": "main"}, {"name": "foo", "file": "foo.py", "line": 3}, {"name": "bar"}]},
+	"profiles": [
+		{
+			"type": "sampled", "name": "sampled", "unit": "milliseconds", "startValue": 0, "endValue": 6,
+			"samples": [[0, 1], [0, 1, 2], [0, 1]], "weights": [1, 2, 3]
+		},
+		{
+			"type": "evented", "name": "evented", "unit": "none", "startValue": 0, "endValue": 10,
+			"events": [
+				{"type": "O", "frame": 0, "at": 0},
+				{"type": "O", "frame": 2, "at": 4},
+				{"type": "C", "frame": 2, "at": 5},
+				{"type": "C", "frame": 0, "at": 10}
+			]
+		}
+	]%s
+}`, activeProfileIndex)
+}
+
+func TestReadSpeedscope(t *testing.T) {
+	time := map[string]int64{"main;foo": 4000000, "main;foo;bar": 2000000}
+	samples := map[string]int64{"main": 9, "main;bar": 1}
+
+	tests := []struct {
+		name string
+		data string
+		mode sampleMode
+		want map[string]int64
+	}{
+		{"time", speedscopeData(""), "time", time},
+		{"samples", speedscopeData(""), "samples", samples},
+		{"first profile by default", speedscopeData(""), ModeDefault, time},
+		{"active profile", speedscopeData(`, "activeProfileIndex": 1`), ModeDefault, samples},
+		{"negative active profile", speedscopeData(`, "activeProfileIndex": -1`), ModeDefault, time},
+		{"unknown active profile", speedscopeData(`, "activeProfileIndex": 2`), ModeDefault, time},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !looksLikeSpeedscope([]byte(test.data)) {
+				t.Errorf("looksLikeSpeedscope() = false, want true")
+			}
+			p, err := readSpeedscope([]byte(test.data))
+			if err != nil {
+				t.Fatalf("readSpeedscope() error = %v", err)
+			}
+			equalStacks(t, foldedStacks(t, p, test.mode), test.want)
+		})
+	}
+}
+
+func TestReadSpeedscopeErrors(t *testing.T) {
+	file := func(frames int, profile string) string {
+		return fmt.Sprintf(`{"shared": {"frames": [%s]}, "profiles": [%s]}`,
+			strings.TrimSuffix(strings.Repeat(`{"name": "f"},`, frames), ","), profile)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"invalid json", `{"profiles": [`, "json.Unmarshal"},
+		{"no profiles", file(1, ""), "no profiles"},
+		{"unknown unit", file(1, `{"type": "sampled", "unit": "parsecs", "samples": [[0]]}`), `profile #0: unknown unit "parsecs"`},
+		{"unknown type", file(1, `{"type": "traced", "unit": "none"}`), `unknown profile type "traced"`},
+		{"unknown frame", file(1, `{"type": "sampled", "unit": "none", "samples": [[0, 1]]}`), "unknown frame 1"},
+		{
+			"unknown event",
+			file(1, `{"type": "evented", "unit": "none", "events": [{"type": "X", "frame": 0, "at": 0}]}`),
+			`unknown event type "X"`,
+		},
+		{
+			"closed but not opened",
+			file(2, `{"type": "evented", "unit": "none", "events": [{"type": "O", "frame": 0, "at": 0}, {"type": "C", "frame": 1, "at": 1}]}`),
+			"frame 1 closed but not opened",
+		},
+	}
+
+	for _, test := range tests {
+		if _, err := readSpeedscope([]byte(test.data)); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: readSpeedscope() error = %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestWriteSpeedscope(t *testing.T) {
+	p, err := readFolded([]byte("main;foo;bar 12\nmain;foo 3\nmain;baz 5\n"))
+	if err != nil {
+		t.Fatalf("readFolded() error = %v", err)
+	}
+	profile, err := NewProfile(p, ModeDefault, nil)
+	if err != nil {
+		t.Fatalf("NewProfile() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	opts := TreeOptions{AggregateByFunction: true, Focus: []Function{{Name: "foo"}}}
+	if err := writeSpeedscope(&buf, profile, "test", opts); err != nil {
+		t.Fatalf("writeSpeedscope() error = %v", err)
+	}
+
+	written, err := readSpeedscope(buf.Bytes())
+	if err != nil {
+		t.Fatalf("readSpeedscope() error = %v", err)
+	}
+	equalStacks(t, foldedStacks(t, written, ModeDefault), map[string]int64{"foo;bar": 12, "foo": 3})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+const (
+	svgWidth     = 1200
+	svgRowHeight = 16
+	svgCharWidth = 7
+	svgTop       = 50
+	svgBottom    = 10
+)
+
+// writeSVG writes the tree as a standalone flame graph: hovering a frame
+// shows its details, clicking on it zooms on it.
+func writeSVG(w io.Writer, profile *Profile, tree *FunctionsTree, opts TreeOptions, icicle bool) error {
+	diff := profile.Base != nil
+	frames, maxDepth := flameGraphLayout(tree, nil, !opts.AggregateByFunction, diff, 0.0001)
+	height := svgTop + (maxDepth+1)*svgRowHeight + svgBottom
+
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, `<?xml version="1.0" standalone="no"?>
+<svg version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg" font-family="Verdana, sans-serif" font-size="12">
+<style>
+g.f:hover rect { stroke: black; stroke-width: 0.5; cursor: pointer; }
+#reset { cursor: pointer; fill: #3060a0; }
+</style>
+<rect width="100%%" height="100%%" fill="#f8f8f8"/>
+<text x="%d" y="20" text-anchor="middle" font-size="15">%s</text>
+<text id="reset" x="10" y="40" visibility="hidden">Reset zoom</text>
+<text id="details" x="10" y="%d"></text>
+`, svgWidth, height, svgWidth, height, svgWidth/2, html.EscapeString(profile.headerText(tree.name, opts)), height-2)
+
+	// the frames, their position is kept as fractions
+	// of the width to zoom in the script
+	// ----------------------
+
+	for _, f := range frames {
+		y := svgTop + f.depth*svgRowHeight
+		if !icicle {
+			y = svgTop + (maxDepth-f.depth)*svgRowHeight
+		}
+
+		label := flameLabel(f)
+		_, _, tooltip, _ := profile.nodeTexts(f.node, opts.AggregateByFunction)
+		title := label + "\n" + tooltip
+		if f.node.function.File != "" {
+			title = fmt.Sprintf("%s %s\n%s", label, f.node.function.File, tooltip)
+		}
+
+		col := flameColor(f, diff)
+		if opts.SearchField != "" && f.node.function.Name != "" && f.node.matches(opts.SearchField) {
+			col = colorSearchMatch
+		}
+
+		x, width := f.x*svgWidth, f.width*svgWidth
+		fmt.Fprintf(b, `<g class="f" data-x="%g" data-w="%g" data-d="%d" data-n="%s"><title>%s</title>`+
+			`<rect x="%.2f" y="%d" width="%.2f" height="%d" fill="rgb(%d,%d,%d)" rx="2"/>`+
+			`<text x="%.2f" y="%d">%s</text></g>`+"\n",
+			f.x, f.width, f.depth, html.EscapeString(label), html.EscapeString(title),
+			x, y, width, svgRowHeight-1, col.R, col.G, col.B,
+			x+3, y+svgRowHeight-4, html.EscapeString(svgLabel(label, width)))
+	}
+
+	fmt.Fprintf(b, "<script><![CDATA[%s]]></script>\n</svg>\n", svgScript)
+
+	return b.Flush()
+}
+
+// svgLabel truncates the label to fit in the given width.
+func svgLabel(label string, width float64) string {
+	chars := int((width - 6) / svgCharWidth)
+	if chars < 3 {
+		return ""
+	}
+	if len(label) > chars {
+		return label[:chars-2] + ".."
+	}
+	return label
+}
+
+var svgScript = strings.NewReplacer(
+	"SVG_WIDTH", fmt.Sprint(svgWidth),
+	"SVG_CHAR_WIDTH", fmt.Sprint(svgCharWidth),
+).Replace(`
+var W = SVG_WIDTH, CHAR = SVG_CHAR_WIDTH;
+var frames = document.querySelectorAll("g.f");
+var reset = document.getElementById("reset");
+var details = document.getElementById("details");
+
+function attr(f, name) { return parseFloat(f.getAttribute("data-" + name)); }
+
+function label(name, width) {
+	var chars = Math.floor((width - 6) / CHAR);
+	if (chars < 3) { return ""; }
+	return name.length > chars ? name.substring(0, chars - 2) + ".." : name;
+}
+
+function place(f, x, w) {
+	var rect = f.querySelector("rect"), text = f.querySelector("text");
+	f.style.display = "";
+	rect.setAttribute("x", x * W);
+	rect.setAttribute("width", w * W);
+	text.setAttribute("x", x * W + 3);
+	text.textContent = label(f.getAttribute("data-n"), w * W);
+}
+
+function zoom(z) {
+	var x = attr(z, "x"), w = attr(z, "w"), d = attr(z, "d"), eps = 1e-9;
+	for (var i = 0; i < frames.length; i++) {
+		var f = frames[i], fx = attr(f, "x"), fw = attr(f, "w"), fd = attr(f, "d");
+		if (fd <= d && fx <= x + eps && fx + fw >= x + w - eps) {
+			place(f, 0, 1); // the zoomed frame and its ancestors
+		} else if (fd > d && fx >= x - eps && fx + fw <= x + w + eps) {
+			place(f, (fx - x) / w, fw / w);
+		} else {
+			f.style.display = "none";
+		}
+	}
+	reset.setAttribute("visibility", d > 0 ? "visible" : "hidden");
+}
+
+for (var i = 0; i < frames.length; i++) {
+	frames[i].addEventListener("click", function() { zoom(this); });
+	frames[i].addEventListener("mouseover", function() { details.textContent = this.querySelector("title").textContent.split("\n").join(" - "); });
+	frames[i].addEventListener("mouseout", function() { details.textContent = ""; });
+}
+reset.addEventListener("click", function() { zoom(frames[0]); });
+`)
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+// svgFrame is a frame of a written SVG flame graph.
+type svgFrame struct {
+	Name  string `xml:"data-n,attr"`
+	Title string `xml:"title"`
+	Rect  struct {
+		X      float64 `xml:"x,attr"`
+		Y      int     `xml:"y,attr"`
+		Width  float64 `xml:"width,attr"`
+		Height int     `xml:"height,attr"`
+		Fill   string  `xml:"fill,attr"`
+	} `xml:"rect"`
+	Text string `xml:"text"`
+}
+
+func TestWriteSVG(t *testing.T) {
+	folded := "main;vector<int>::push_back;b 6\nmain;c 2\n"
+
+	type frame struct {
+		name     string
+		x, width float64
+		y        int
+	}
+
+	tests := []struct {
+		name   string
+		opts   TreeOptions
+		icicle bool
+		want   []frame
+		// the frame colored as a search match
+		match string
+	}{
+		{
+			name: "flame graph",
+			want: []frame{
+				{"all", 0, 1200, 50 + 3*16},
+				{"main", 0, 1200, 50 + 2*16},
+				{"vector<int>::push_back", 0, 900, 50 + 16},
+				{"b", 0, 900, 50},
+				{"c", 900, 300, 50 + 16},
+			},
+		},
+		{
+			name:   "icicle graph",
+			icicle: true,
+			want: []frame{
+				{"all", 0, 1200, 50},
+				{"main", 0, 1200, 50 + 16},
+				{"vector<int>::push_back", 0, 900, 50 + 2*16},
+				{"b", 0, 900, 50 + 3*16},
+				{"c", 900, 300, 50 + 2*16},
+			},
+		},
+		{
+			name: "focus and search",
+			opts: TreeOptions{Focus: []Function{{Name: "c"}}, SearchField: "c"},
+			want: []frame{
+				{"all", 0, 1200, 50 + 16},
+				{"c", 0, 1200, 50},
+			},
+			match: "c",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			profile, tree := foldedTree(t, folded, "", test.opts)
+
+			var buf bytes.Buffer
+			if err := writeSVG(&buf, profile, tree, test.opts, test.icicle); err != nil {
+				t.Fatalf("writeSVG() error = %v", err)
+			}
+
+			var svg struct {
+				XMLName xml.Name   `xml:"svg"`
+				Frames  []svgFrame `xml:"g"`
+			}
+			if err := xml.Unmarshal(buf.Bytes(), &svg); err != nil {
+				t.Fatalf("xml.Unmarshal() error = %v", err)
+			}
+
+			if len(svg.Frames) != len(test.want) {
+				t.Fatalf("got %d frames, want %d", len(svg.Frames), len(test.want))
+			}
+			for i, f := range svg.Frames {
+				want := test.want[i]
+				if f.Name != want.name || f.Rect.X != want.x || f.Rect.Width != want.width || f.Rect.Y != want.y {
+					t.Errorf("frame %d = {%q %v %v %d}, want %+v", i, f.Name, f.Rect.X, f.Rect.Width, f.Rect.Y, want)
+				}
+				if f.Rect.Height != svgRowHeight-1 {
+					t.Errorf("frame %d height = %d, want %d", i, f.Rect.Height, svgRowHeight-1)
+				}
+				if f.Text != svgLabel(want.name, want.width) {
+					t.Errorf("frame %d text = %q, want %q", i, f.Text, svgLabel(want.name, want.width))
+				}
+
+				searchColor := f.Rect.Fill == "rgb(230,0,230)"
+				if searchColor != (f.Name == test.match) {
+					t.Errorf("frame %d %q fill = %s", i, f.Name, f.Rect.Fill)
+				}
+			}
+		})
+	}
+}
+
+func TestSVGLabel(t *testing.T) {
+	tests := []struct {
+		label string
+		width float64
+		want  string
+	}{
+		{"main", 100, "main"},
+		{"runtime.mallocgc", 60, "runti.."},
+		{"main", 20, ""},
+	}
+
+	for _, test := range tests {
+		if got := svgLabel(test.label, test.width); got != test.want {
+			t.Errorf("svgLabel(%q, %v) = %q, want %q", test.label, test.width, got, test.want)
+		}
+	}
+}
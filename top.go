@@ -0,0 +1,128 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// topEntry is a line of the flat top table, the equivalent of pprof -top.
+type topEntry struct {
+	function Function
+
+	// flat is the value of the samples in which the function is the
+	// leaf, cum the value of the samples in which it appears.
+	flat int64
+	cum  int64
+
+	flatPercent float64
+	cumPercent  float64
+	// sumPercent is the sum of the flatPercent of this entry
+	// and of all the entries before it.
+	sumPercent float64
+}
+
+// topSort is the column used to sort the top table.
+type topSort string
+
+var (
+	TopSortFlat topSort = "flat"
+	TopSortCum  topSort = "cum"
+	TopSortName topSort = "name"
+)
+
+// Top returns one entry per function (or per line of code depending
+// on opts.AggregateByFunction) with its flat and cumulative values,
+// sorted by the given column.
+// Only the entries matching opts.SearchField are returned, and the
+// stacks are the ones of the tree built with the same options.
+func (p *Profile) Top(opts TreeOptions, sortBy topSort) []topEntry {
+	samples := p.Samples
+	total := float64(p.TotalSampling)
+	if p.Base != nil {
+		samples = p.diffSamples()
+		total = p.diffTotal()
+	}
+
+	entries := make(map[string]*topEntry)
+	entry := func(f Function) *topEntry {
+		id := f.String(!opts.AggregateByFunction)
+		e, ok := entries[id]
+		if !ok {
+			f.Self = 0
+			e = &topEntry{function: f}
+			entries[id] = e
+		}
+		return e
+	}
+
+	for _, s := range samples {
+		// the same stacks as in the tree: filtered, collapsed,
+		// inverted, grouped and focused.
+		functions, ok := opts.stack(s)
+		if !ok || len(functions) == 0 {
+			continue
+		}
+
+		// a recursive function appears several times in the
+		// stack, only count it once in its cumulative value.
+		// The leaf is the last function of the stack, or the
+		// first one in inverted mode, it carries the self value.
+		seen := make(map[*topEntry]bool)
+		for _, f := range functions {
+			e := entry(f)
+			if !seen[e] {
+				e.cum += s.Value
+				seen[e] = true
+			}
+			e.flat += f.Self
+		}
+	}
+
+	// sort the entries and compute the percentages
+	// ----------------------
+
+	rv := make([]topEntry, 0, len(entries))
+	for _, e := range entries {
+		e.flatPercent = float64(e.flat) / total * 100.0
+		e.cumPercent = float64(e.cum) / total * 100.0
+		rv = append(rv, *e)
+	}
+
+	sort.Slice(rv, func(i, j int) bool {
+		switch sortBy {
+		case TopSortCum:
+			if abs(rv[i].cum) != abs(rv[j].cum) {
+				return abs(rv[i].cum) > abs(rv[j].cum)
+			}
+		case TopSortFlat:
+			if abs(rv[i].flat) != abs(rv[j].flat) {
+				return abs(rv[i].flat) > abs(rv[j].flat)
+			}
+		}
+		return rv[i].function.String(!opts.AggregateByFunction) < rv[j].function.String(!opts.AggregateByFunction)
+	})
+
+	var sum float64
+	for i := range rv {
+		sum += rv[i].flatPercent
+		rv[i].sumPercent = sum
+	}
+
+	// filter once the sums have been computed to not
+	// alter them.
+	// ----------------------
+
+	if opts.SearchField == "" {
+		return rv
+	}
+
+	search := strings.ToLower(opts.SearchField)
+	filtered := rv[:0]
+	for _, e := range rv {
+		if strings.Contains(strings.ToLower(e.function.Name), search) ||
+			strings.Contains(strings.ToLower(e.function.File), search) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+)
+
+// stackSample returns a sample of the given stack, the root first.
+func stackSample(value int64, labels []Label, names ...string) Sample {
+	s := Sample{Value: value, Labels: labels}
+	for _, name := range names {
+		s.Functions = append(s.Functions, Function{Name: name})
+	}
+	s.Functions[len(s.Functions)-1].Self = value
+	return s
+}
+
+func TestTop(t *testing.T) {
+	profile := &Profile{
+		Samples: Samples{
+			stackSample(10, nil, "main", "x", "leaf"),
+			stackSample(5, []Label{{Key: "k", Str: "v"}}, "main", "y", "leaf"),
+			stackSample(3, nil, "main", "a", "b", "a", "b"),
+		},
+		TotalSampling: 18,
+	}
+
+	type row struct {
+		flat, cum int64
+	}
+
+	tests := []struct {
+		name string
+		opts TreeOptions
+		want map[string]row
+	}{
+		{
+			name: "all",
+			opts: TreeOptions{},
+			want: map[string]row{
+				"main": {0, 18}, "x": {0, 10}, "y": {0, 5}, "leaf": {15, 15},
+				"a": {0, 3}, "b": {3, 3},
+			},
+		},
+		{
+			name: "focus",
+			opts: TreeOptions{Focus: []Function{{Name: "x"}}},
+			want: map[string]row{"x": {0, 10}, "leaf": {10, 10}},
+		},
+		{
+			name: "inverted nested focus",
+			opts: TreeOptions{Inverted: true, Focus: []Function{{Name: "leaf"}, {Name: "x"}}},
+			want: map[string]row{"x": {0, 10}, "main": {0, 10}},
+		},
+		{
+			name: "tag focus",
+			opts: TreeOptions{TagFocus: []tagFilter{{key: "k", value: "v"}}},
+			want: map[string]row{"main": {0, 5}, "y": {0, 5}, "leaf": {5, 5}},
+		},
+		{
+			name: "group by focus",
+			opts: TreeOptions{GroupBy: "k", Focus: []Function{{Name: "k (unlabeled)"}}},
+			want: map[string]row{
+				"k (unlabeled)": {0, 13}, "main": {0, 13}, "x": {0, 10}, "leaf": {10, 10},
+				"a": {0, 3}, "b": {3, 3},
+			},
+		},
+		{
+			name: "collapsed recursion",
+			opts: TreeOptions{CollapseRecursion: true, Focus: []Function{{Name: "a"}}},
+			want: map[string]row{"a": {0, 3}, "b": {3, 3}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.AggregateByFunction = true
+			got := make(map[string]row)
+			for _, e := range profile.Top(test.opts, TopSortName) {
+				got[e.function.Name] = row{e.flat, e.cum}
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("Top() = %v, want %v", got, test.want)
+			}
+			for name, want := range test.want {
+				if got[name] != want {
+					t.Errorf("Top() %s = %v, want %v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
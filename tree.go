@@ -27,6 +27,16 @@ type treeNode struct {
 	value    int64
 	percent  float64
 	visible  bool
+
+	// used to compute the average object size in heap profiles
+	bytes   int64
+	objects int64
+
+	// value in the base profile of a differential view
+	base int64
+
+	// maximum number of recursive calls collapsed in this node
+	recursion int
 }
 
 func NewFunctionsTree(treeName string) *FunctionsTree {
@@ -43,16 +53,22 @@ func (n treeNode) ID(lineNumber bool) string {
 	return n.function.String(lineNumber)
 }
 
-// AddFunction adds the given function to the tree.
+// AddFunction adds the given function of the given sample to the tree.
 // AddFunction takes care of aggregating the values per functions calls or line of
 // code depending on the aggregateByFunction parameter.
-func (n *treeNode) AddFunction(f Function, value, self int64, percent float64, aggregateByFunction bool) *treeNode {
+func (n *treeNode) AddFunction(f Function, s Sample, aggregateByFunction bool) *treeNode {
 	for i, child := range n.children {
 		// if existing, we add the values to the current node
 		if child.ID(!aggregateByFunction) == f.String(!aggregateByFunction) {
-			child.value += value
-			child.self += self
-			child.percent += percent
+			child.value += s.Value
+			child.self += f.Self
+			child.percent += s.PercentTotal
+			child.bytes += s.Bytes
+			child.objects += s.Objects
+			child.base += s.BaseValue
+			if f.Recursion > child.recursion {
+				child.recursion = f.Recursion
+			}
 			n.children[i] = child
 			return child
 		}
@@ -60,28 +76,45 @@ func (n *treeNode) AddFunction(f Function, value, self int64, percent float64, a
 
 	// doesn't exist, create it
 	node := &treeNode{
-		function: f,
-		value:    value,
-		self:     self,
-		percent:  percent,
+		function:  f,
+		value:     s.Value,
+		self:      f.Self,
+		percent:   s.PercentTotal,
+		bytes:     s.Bytes,
+		objects:   s.Objects,
+		base:      s.BaseValue,
+		recursion: f.Recursion,
 	}
 
 	n.children = append(n.children, node)
 	return node
 }
 
+// averageObjectSize returns the average size in bytes of the objects
+// allocated under this node, 0 if unknown.
+func (n *treeNode) averageObjectSize() int64 {
+	if n.objects == 0 {
+		return 0
+	}
+	return n.bytes / n.objects
+}
+
 func (n *treeNode) isLeaf() bool {
 	return len(n.children) == 0
 }
 
+// matches returns true if the function of the node matches the search.
+func (n *treeNode) matches(searchField string) bool {
+	return strings.Contains(strings.ToLower(n.function.Name), strings.ToLower(searchField)) ||
+		strings.Contains(strings.ToLower(n.function.File), strings.ToLower(searchField))
+}
+
 func (n *treeNode) filter(searchField string) bool {
 	var visible bool
 
 	if searchField == "" || n.function.Name == "" {
 		visible = true
-	} else if strings.Contains(strings.ToLower(n.function.Name), strings.ToLower(searchField)) {
-		visible = true
-	} else if strings.Contains(strings.ToLower(n.function.File), strings.ToLower(searchField)) {
+	} else if n.matches(searchField) {
 		visible = true
 	}
 
@@ -95,14 +128,23 @@ func (n *treeNode) filter(searchField string) bool {
 	return n.visible
 }
 
+// sort sorts the children by absolute value, to have both the biggest
+// growths and shrinks first in differential views.
 func (n *treeNode) sort() {
 	sort.Slice(
 		n.children,
 		func(i, j int) bool {
-			return n.children[i].value > n.children[j].value
+			return abs(n.children[i].value) > abs(n.children[j].value)
 		},
 	)
 	for _, child := range n.children {
 		child.sort()
 	}
 }
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -7,10 +7,43 @@ type LocationsMap map[uint64]Location
 type FunctionsMap map[uint64]Function
 type ManyFunctionsMap map[uint64][]Function // TODO(remy): better naming...
 
+// ValueType describes a value of the samples, e.g. "alloc_space" in "bytes".
+type ValueType struct {
+	Type string
+	Unit string
+}
+
 type Sample struct {
 	Functions    []Function
 	Value        int64
 	PercentTotal float64
+
+	// allocated or in-use memory and objects count,
+	// only set in heap profiles.
+	Bytes   int64
+	Objects int64
+
+	// value in the base profile when computing a differential view
+	BaseValue int64
+
+	// labels of the sample, e.g. set with pprof.Do
+	Labels []Label
+}
+
+// Label is a label of a sample, it has either a string or a numeric value.
+type Label struct {
+	Key     string
+	Str     string
+	Num     int64
+	NumUnit string
+	// Numeric is true if the label has a numeric value, an empty
+	// string label isn't a numeric one.
+	Numeric bool
+}
+
+// IsNumeric returns true if the label has a numeric value.
+func (l Label) IsNumeric() bool {
+	return l.Numeric
 }
 
 type Samples []Sample
@@ -24,6 +57,9 @@ type Function struct {
 	File       string
 	LineNumber uint64
 	Self       int64
+	// Recursion is the number of calls of the function collapsed
+	// in this frame when collapsing the recursions, 0 otherwise.
+	Recursion int
 }
 
 func (f Function) String(lineNumber bool) string {